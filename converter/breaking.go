@@ -0,0 +1,520 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Severity grades a finding.
+type Severity string
+
+const (
+	// SeverityError marks a wire-incompatible change.
+	SeverityError Severity = "error"
+	// SeverityWarning marks a change that keeps the binary encoding but breaks JSON, text format or generated code.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo marks a compatible change worth noting.
+	SeverityInfo Severity = "info"
+)
+
+// BreakingChange is one difference between a previous export and the current one.
+type BreakingChange struct {
+	Severity Severity
+	File     string
+	Element  string
+	Message  string
+}
+
+func (c BreakingChange) String() string {
+	return fmt.Sprintf("%s: %s: %s: %s", c.Severity, c.File, c.Element, c.Message)
+}
+
+// Breaking exports into a scratch directory and compares every target with its previous export.
+// against is a directory of .proto files, a manifest or git:<ref>, which reads each target's
+// export directory at that revision of the local repository. The manifest of an export is the
+// descriptor set it wrote with descriptorSet (binary) or descriptorJson (protojson).
+func (e *Exporter) Breaking(against string) ([]BreakingChange, error) {
+	tmp, err := os.MkdirTemp("", "proto-converter-breaking-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	var dirs []string
+	err = e.run(runHooks{target: func(i int, t *exportTarget) {
+		dirs = append(dirs, t.Dir)
+		t.Dir = filepath.Join(tmp, strconv.Itoa(i))
+		t.DescriptorSet, t.DescriptorJSON, t.JSONSchema = "", "", ""
+	}})
+	if err != nil {
+		return nil, err
+	}
+	var out []BreakingChange
+	for i, dir := range dirs {
+		cur, err := schemaFromDir(filepath.Join(tmp, strconv.Itoa(i)))
+		if err != nil {
+			return nil, fmt.Errorf("解析当前导出失败 (%s): %w", dir, err)
+		}
+		prev, err := previousSchema(against, dir, len(dirs))
+		if err != nil {
+			return nil, fmt.Errorf("读取上次导出失败 (%s): %w", dir, err)
+		}
+		for _, c := range compareSchemas(prev, cur) {
+			c.File = shortPath(filepath.Join(dir, filepath.FromSlash(c.File)))
+			out = append(out, c)
+		}
+	}
+	sortChanges(out)
+	return out, nil
+}
+
+// previousSchema loads the previous export of the target written to dir.
+func previousSchema(against, dir string, targets int) (*descriptorpb.FileDescriptorSet, error) {
+	if ref, ok := strings.CutPrefix(against, "git:"); ok {
+		sources, err := gitSources(ref, dir)
+		if err != nil {
+			return nil, err
+		}
+		set, _, err := buildDescriptorSet(sources, false)
+		return set, err
+	}
+	info, err := os.Stat(against)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		// 多个导出目标时，各目标的上次导出位于 against 下与 export.dir 相同的相对路径
+		if targets > 1 {
+			return schemaFromDir(filepath.Join(against, dir))
+		}
+		return schemaFromDir(against)
+	}
+	if targets > 1 {
+		return nil, fmt.Errorf("存在多个导出目标时 --against 需为目录或 git:<ref>")
+	}
+	if strings.EqualFold(filepath.Ext(against), ".json") {
+		b, err := os.ReadFile(against)
+		if err != nil {
+			return nil, err
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, set); err != nil {
+			return nil, fmt.Errorf("%s: 不是有效的 FileDescriptorSet: %w", against, err)
+		}
+		return set, nil
+	}
+	return loadDescriptorSet(against)
+}
+
+// schemaFromDir compiles every .proto under dir, named by its slash path relative to dir.
+func schemaFromDir(dir string) (*descriptorpb.FileDescriptorSet, error) {
+	sources := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".proto" {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		sources[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%s 下没有 .proto 文件", dir)
+	}
+	set, _, err := buildDescriptorSet(sources, false)
+	return set, err
+}
+
+// gitSources reads the .proto files under dir (relative to the working directory) at ref.
+func gitSources(ref, dir string) (map[string]string, error) {
+	prefix := filepath.ToSlash(filepath.Clean(dir)) + "/"
+	list, err := git("ls-tree", "-r", "--name-only", ref, "--", prefix)
+	if err != nil {
+		return nil, err
+	}
+	sources := map[string]string{}
+	for _, p := range strings.Split(strings.TrimSpace(list), "\n") {
+		if !strings.HasSuffix(p, ".proto") {
+			continue
+		}
+		data, err := git("show", ref+":./"+p)
+		if err != nil {
+			return nil, err
+		}
+		sources[strings.TrimPrefix(p, prefix)] = data
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%s 中 %s 下没有 .proto 文件", ref, dir)
+	}
+	return sources, nil
+}
+
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// schemaIndex holds the definitions of a descriptor set by full name (without leading dot).
+type schemaIndex struct {
+	files    map[string]*descriptorpb.FileDescriptorProto
+	messages map[string]*descriptorpb.DescriptorProto
+	enums    map[string]*descriptorpb.EnumDescriptorProto
+	services map[string]*descriptorpb.ServiceDescriptorProto
+	fileOf   map[string]string
+}
+
+func (ix schemaIndex) syntaxOf(name string) string {
+	return ix.files[ix.fileOf[name]].GetSyntax()
+}
+
+func indexSchema(set *descriptorpb.FileDescriptorSet) schemaIndex {
+	ix := schemaIndex{
+		files:    map[string]*descriptorpb.FileDescriptorProto{},
+		messages: map[string]*descriptorpb.DescriptorProto{},
+		enums:    map[string]*descriptorpb.EnumDescriptorProto{},
+		services: map[string]*descriptorpb.ServiceDescriptorProto{},
+		fileOf:   map[string]string{},
+	}
+	var walk func(file, scope string, msgs []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto)
+	walk = func(file, scope string, msgs []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto) {
+		for _, e := range enums {
+			ix.enums[scope+e.GetName()] = e
+			ix.fileOf[scope+e.GetName()] = file
+		}
+		for _, m := range msgs {
+			ix.messages[scope+m.GetName()] = m
+			ix.fileOf[scope+m.GetName()] = file
+			walk(file, scope+m.GetName()+".", m.NestedType, m.EnumType)
+		}
+	}
+	for _, fd := range set.File {
+		// well-known 依赖不属于导出内容
+		if strings.HasPrefix(fd.GetName(), "google/protobuf/") {
+			continue
+		}
+		ix.files[fd.GetName()] = fd
+		scope := ""
+		if fd.GetPackage() != "" {
+			scope = fd.GetPackage() + "."
+		}
+		walk(fd.GetName(), scope, fd.MessageType, fd.EnumType)
+		for _, s := range fd.Service {
+			ix.services[scope+s.GetName()] = s
+			ix.fileOf[scope+s.GetName()] = fd.GetName()
+		}
+	}
+	return ix
+}
+
+// schemaDiff compares two indexed schemas; renamed maps old full names of definitions whose
+// file changed package to their new full names.
+type schemaDiff struct {
+	prev, cur schemaIndex
+	renamed   map[string]string
+	out       []BreakingChange
+}
+
+func (d *schemaDiff) add(sev Severity, file, element, format string, args ...any) {
+	d.out = append(d.out, BreakingChange{Severity: sev, File: file, Element: element, Message: fmt.Sprintf(format, args...)})
+}
+
+// newName maps an old full name (with or without leading dot) to its name in the current schema.
+func (d *schemaDiff) newName(name string) string {
+	name = strings.TrimPrefix(name, ".")
+	if n, ok := d.renamed[name]; ok {
+		return n
+	}
+	return name
+}
+
+// compareSchemas reports the changes from prev to cur, file by file and then by full name.
+func compareSchemas(prev, cur *descriptorpb.FileDescriptorSet) []BreakingChange {
+	d := &schemaDiff{prev: indexSchema(prev), cur: indexSchema(cur), renamed: map[string]string{}}
+	for _, name := range sortedKeys(d.prev.files) {
+		of, nf := d.prev.files[name], d.cur.files[name]
+		if nf == nil || of.GetPackage() == nf.GetPackage() {
+			continue
+		}
+		d.add(SeverityError, name, of.GetPackage(), "package 由 %q 变为 %q（类型全名与 RPC 路径随之改变）", of.GetPackage(), nf.GetPackage())
+		for full, file := range d.prev.fileOf {
+			if file != name {
+				continue
+			}
+			rest := full
+			if of.GetPackage() != "" {
+				rest = strings.TrimPrefix(full, of.GetPackage()+".")
+			}
+			if nf.GetPackage() != "" {
+				rest = nf.GetPackage() + "." + rest
+			}
+			d.renamed[full] = rest
+		}
+	}
+	for _, name := range sortedKeys(d.prev.messages) {
+		om := d.prev.messages[name]
+		nm := d.cur.messages[d.newName(name)]
+		if nm == nil {
+			if !om.GetOptions().GetMapEntry() {
+				d.add(SeverityError, d.prev.fileOf[name], name, "消息已删除")
+			}
+			continue
+		}
+		d.message(name, d.newName(name), om, nm)
+	}
+	for _, name := range sortedKeys(d.prev.enums) {
+		ne := d.cur.enums[d.newName(name)]
+		if ne == nil {
+			d.add(SeverityError, d.prev.fileOf[name], name, "枚举已删除")
+			continue
+		}
+		d.enum(d.cur.fileOf[d.newName(name)], d.newName(name), d.prev.enums[name], ne)
+	}
+	for _, name := range sortedKeys(d.prev.services) {
+		ns := d.cur.services[d.newName(name)]
+		if ns == nil {
+			d.add(SeverityError, d.prev.fileOf[name], name, "服务已删除")
+			continue
+		}
+		d.service(d.cur.fileOf[d.newName(name)], d.newName(name), d.prev.services[name], ns)
+	}
+	return d.out
+}
+
+func (d *schemaDiff) message(oldName, name string, om, nm *descriptorpb.DescriptorProto) {
+	file := d.cur.fileOf[name]
+	newByNum := map[int32]*descriptorpb.FieldDescriptorProto{}
+	newByName := map[string]*descriptorpb.FieldDescriptorProto{}
+	for _, f := range nm.Field {
+		newByNum[f.GetNumber()] = f
+		newByName[f.GetName()] = f
+	}
+	for _, of := range om.Field {
+		el := name + "." + of.GetName()
+		nf := newByNum[of.GetNumber()]
+		if nf == nil {
+			switch moved := newByName[of.GetName()]; {
+			case moved != nil:
+				d.add(SeverityError, file, el, "字段编号由 %d 变为 %d", of.GetNumber(), moved.GetNumber())
+			case inReserved(nm.ReservedRange, of.GetNumber()):
+				d.add(SeverityInfo, file, el, "字段 (%d) 已删除，编号已保留", of.GetNumber())
+			default:
+				d.add(SeverityError, file, el, "字段 (%d) 已删除且编号未保留", of.GetNumber())
+			}
+			continue
+		}
+		if nf.GetName() != of.GetName() {
+			d.add(SeverityWarning, file, el, "字段 %d 改名为 %s（JSON 与文本格式不兼容）", of.GetNumber(), nf.GetName())
+			el = name + "." + nf.GetName()
+		}
+		d.fieldType(file, el, of, nf)
+		d.cardinality(oldName, name, file, el, om, nm, of, nf)
+	}
+	for _, nf := range nm.Field {
+		if inReserved(om.ReservedRange, nf.GetNumber()) {
+			d.add(SeverityError, file, name+"."+nf.GetName(), "重用了已保留的编号 %d", nf.GetNumber())
+		}
+		for _, r := range om.ReservedName {
+			if r == nf.GetName() {
+				d.add(SeverityWarning, file, name+"."+nf.GetName(), "重用了已保留的字段名")
+			}
+		}
+	}
+}
+
+// wireClasses groups scalar types that share an encoding; changing within a group keeps the wire format.
+var wireClasses = map[descriptorpb.FieldDescriptorProto_Type]string{
+	descriptorpb.FieldDescriptorProto_TYPE_INT32:    "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_INT64:    "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_UINT32:   "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_UINT64:   "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL:     "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_ENUM:     "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_SINT32:   "zigzag",
+	descriptorpb.FieldDescriptorProto_TYPE_SINT64:   "zigzag",
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED32:  "fixed32",
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED32: "fixed32",
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED64:  "fixed64",
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED64: "fixed64",
+	descriptorpb.FieldDescriptorProto_TYPE_STRING:   "bytes",
+	descriptorpb.FieldDescriptorProto_TYPE_BYTES:    "bytes",
+}
+
+func (d *schemaDiff) fieldType(file, el string, of, nf *descriptorpb.FieldDescriptorProto) {
+	ot, nt := of.GetType(), nf.GetType()
+	oName, nName := d.newName(of.GetTypeName()), strings.TrimPrefix(nf.GetTypeName(), ".")
+	if ot == nt && oName == nName {
+		return
+	}
+	from, to := fieldTypeName(of), fieldTypeName(nf)
+	switch {
+	case ot == nt && ot == descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		d.add(SeverityWarning, file, el, "枚举类型由 %s 变为 %s", from, to)
+	case ot == nt:
+		d.add(SeverityError, file, el, "消息类型由 %s 变为 %s", from, to)
+	case wireClasses[ot] != "" && wireClasses[ot] == wireClasses[nt]:
+		d.add(SeverityWarning, file, el, "类型由 %s 变为 %s（编码兼容，但取值范围或含义可能改变）", from, to)
+	default:
+		d.add(SeverityError, file, el, "类型由 %s 变为 %s", from, to)
+	}
+}
+
+func fieldTypeName(f *descriptorpb.FieldDescriptorProto) string {
+	if f.GetTypeName() != "" {
+		return strings.TrimPrefix(f.GetTypeName(), ".")
+	}
+	return strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+}
+
+func (d *schemaDiff) cardinality(oldName, name, file, el string, om, nm *descriptorpb.DescriptorProto, of, nf *descriptorpb.FieldDescriptorProto) {
+	ol, nl := of.GetLabel(), nf.GetLabel()
+	from, to := labelName(of, d.prev.syntaxOf(oldName)), labelName(nf, d.cur.syntaxOf(name))
+	switch {
+	case ol != nl:
+		// repeated 与单值编码不同；required 的增减会让旧消息在另一端解析失败
+		d.add(SeverityError, file, el, "基数由 %s 变为 %s", from, to)
+	case of.GetProto3Optional() != nf.GetProto3Optional():
+		d.add(SeverityWarning, file, el, "基数由 %s 变为 %s（字段存在性语义改变）", from, to)
+	}
+	oo, no := realOneof(om, of), realOneof(nm, nf)
+	if oo != no {
+		switch {
+		case oo == "":
+			d.add(SeverityWarning, file, el, "字段移入 oneof %s", no)
+		case no == "":
+			d.add(SeverityWarning, file, el, "字段移出 oneof %s", oo)
+		default:
+			d.add(SeverityWarning, file, el, "字段由 oneof %s 移到 oneof %s", oo, no)
+		}
+	}
+}
+
+// labelName spells the cardinality of f; proto3 fields without presence are "singular".
+func labelName(f *descriptorpb.FieldDescriptorProto, syntax string) string {
+	switch f.GetLabel() {
+	case descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		return "repeated"
+	case descriptorpb.FieldDescriptorProto_LABEL_REQUIRED:
+		return "required"
+	}
+	if syntax == "proto3" && !f.GetProto3Optional() {
+		return "singular"
+	}
+	return "optional"
+}
+
+// realOneof names the declared oneof of f, ignoring synthetic proto3 optional oneofs.
+func realOneof(m *descriptorpb.DescriptorProto, f *descriptorpb.FieldDescriptorProto) string {
+	if f.OneofIndex == nil || f.GetProto3Optional() {
+		return ""
+	}
+	return m.OneofDecl[f.GetOneofIndex()].GetName()
+}
+
+func inReserved(ranges []*descriptorpb.DescriptorProto_ReservedRange, n int32) bool {
+	for _, r := range ranges {
+		if n >= r.GetStart() && n < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *schemaDiff) enum(file, name string, oe, ne *descriptorpb.EnumDescriptorProto) {
+	newByNum := map[int32][]string{}
+	newByName := map[string]int32{}
+	for _, v := range ne.Value {
+		newByNum[v.GetNumber()] = append(newByNum[v.GetNumber()], v.GetName())
+		newByName[v.GetName()] = v.GetNumber()
+	}
+	reserved := func(e *descriptorpb.EnumDescriptorProto, n int32) bool {
+		// 枚举保留区间的 end 为闭区间
+		for _, r := range e.ReservedRange {
+			if n >= r.GetStart() && n <= r.GetEnd() {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ov := range oe.Value {
+		el := name + "." + ov.GetName()
+		names, ok := newByNum[ov.GetNumber()]
+		if !ok {
+			if n, moved := newByName[ov.GetName()]; moved {
+				d.add(SeverityError, file, el, "枚举值编号由 %d 变为 %d", ov.GetNumber(), n)
+			} else if reserved(ne, ov.GetNumber()) {
+				d.add(SeverityInfo, file, el, "枚举值 (%d) 已删除，编号已保留", ov.GetNumber())
+			} else {
+				d.add(SeverityError, file, el, "枚举值 (%d) 已删除且编号未保留", ov.GetNumber())
+			}
+			continue
+		}
+		if !containsString(names, ov.GetName()) {
+			d.add(SeverityWarning, file, el, "枚举值 %d 改名为 %s（JSON 与文本格式不兼容）", ov.GetNumber(), strings.Join(names, "/"))
+		}
+	}
+	for _, nv := range ne.Value {
+		if reserved(oe, nv.GetNumber()) {
+			d.add(SeverityError, file, name+"."+nv.GetName(), "重用了已保留的编号 %d", nv.GetNumber())
+		}
+	}
+}
+
+func (d *schemaDiff) service(file, name string, prev, cur *descriptorpb.ServiceDescriptorProto) {
+	methods := map[string]*descriptorpb.MethodDescriptorProto{}
+	for _, m := range cur.Method {
+		methods[m.GetName()] = m
+	}
+	for _, om := range prev.Method {
+		el := name + "." + om.GetName()
+		nm := methods[om.GetName()]
+		if nm == nil {
+			d.add(SeverityError, file, el, "方法已删除")
+			continue
+		}
+		if in := strings.TrimPrefix(nm.GetInputType(), "."); d.newName(om.GetInputType()) != in {
+			d.add(SeverityError, file, el, "请求类型由 %s 变为 %s", strings.TrimPrefix(om.GetInputType(), "."), in)
+		}
+		if out := strings.TrimPrefix(nm.GetOutputType(), "."); d.newName(om.GetOutputType()) != out {
+			d.add(SeverityError, file, el, "响应类型由 %s 变为 %s", strings.TrimPrefix(om.GetOutputType(), "."), out)
+		}
+		if om.GetClientStreaming() != nm.GetClientStreaming() || om.GetServerStreaming() != nm.GetServerStreaming() {
+			d.add(SeverityError, file, el, "流式类型改变")
+		}
+	}
+}
+
+// HasErrors reports whether any change is wire-incompatible.
+func HasErrors(changes []BreakingChange) bool {
+	for _, c := range changes {
+		if c.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// sortChanges orders changes by file; changes of one file keep their discovery order.
+func sortChanges(changes []BreakingChange) {
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].File < changes[j].File })
+}
@@ -0,0 +1,174 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	const base = `syntax = "proto3";
+package p;
+message M {
+  int32 a = 1;
+  string b = 2;
+  repeated int32 c = 3;
+  reserved 9;
+}
+enum E { E_ZERO = 0; E_ONE = 1; }
+service S { rpc Get (M) returns (M); }
+`
+	tests := []struct {
+		name string
+		next string
+		want []string
+	}{
+		{"unchanged", base, nil},
+		{"field removed", `syntax = "proto3"; package p;
+message M { string b = 2; repeated int32 c = 3; reserved 9; }
+enum E { E_ZERO = 0; E_ONE = 1; }
+service S { rpc Get (M) returns (M); }`,
+			[]string{"error p.M.a 字段 (1) 已删除且编号未保留"}},
+		{"field removed and reserved", `syntax = "proto3"; package p;
+message M { string b = 2; repeated int32 c = 3; reserved 1, 9; }
+enum E { E_ZERO = 0; E_ONE = 1; }
+service S { rpc Get (M) returns (M); }`,
+			[]string{"info p.M.a 字段 (1) 已删除，编号已保留"}},
+		{"number, type and cardinality", `syntax = "proto3"; package p;
+message M { int64 a = 1; bytes b = 4; int32 c = 3; int32 d = 9; }
+enum E { E_ZERO = 0; E_ONE = 1; }
+service S { rpc Get (M) returns (M); }`,
+			[]string{
+				"warning p.M.a 类型由 int32 变为 int64（编码兼容，但取值范围或含义可能改变）",
+				"error p.M.b 字段编号由 2 变为 4",
+				"error p.M.c 基数由 repeated 变为 singular",
+				"error p.M.d 重用了已保留的编号 9",
+			}},
+		{"incompatible type and presence", `syntax = "proto3"; package p;
+message M { double a = 1; optional string b = 2; repeated int32 c = 3; reserved 9; }
+enum E { E_ZERO = 0; E_ONE = 1; }
+service S { rpc Get (M) returns (M); }`,
+			[]string{
+				"error p.M.a 类型由 int32 变为 double",
+				"warning p.M.b 基数由 singular 变为 optional（字段存在性语义改变）",
+			}},
+		{"enum values", `syntax = "proto3"; package p;
+message M { int32 a = 1; string b = 2; repeated int32 c = 3; reserved 9; }
+enum E { E_NONE = 0; }
+service S { rpc Get (M) returns (M); }`,
+			[]string{
+				"warning p.E.E_ZERO 枚举值 0 改名为 E_NONE（JSON 与文本格式不兼容）",
+				"error p.E.E_ONE 枚举值 (1) 已删除且编号未保留",
+			}},
+		{"types removed", `syntax = "proto3"; package p;
+message M { int32 a = 1; string b = 2; repeated int32 c = 3; reserved 9; }
+service S { rpc Get (M) returns (M); }
+message N { map<string, int32> m = 1; }`,
+			[]string{"error p.E 枚举已删除"}},
+		{"message removed", `syntax = "proto3"; package p;
+enum E { E_ZERO = 0; E_ONE = 1; }`,
+			[]string{"error p.M 消息已删除", "error p.S 服务已删除"}},
+		{"package and service", `syntax = "proto3"; package q;
+message M { int32 a = 1; string b = 2; repeated int32 c = 3; reserved 9; }
+enum E { E_ZERO = 0; E_ONE = 1; }
+service S { rpc Get (stream M) returns (M); }`,
+			[]string{
+				`error p package 由 "p" 变为 "q"（类型全名与 RPC 路径随之改变）`,
+				"error q.S.Get 流式类型改变",
+			}},
+	}
+	prev, _, err := buildDescriptorSet(map[string]string{"a.proto": base}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur, _, err := buildDescriptorSet(map[string]string{"a.proto": tt.next}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range compareSchemas(prev, cur) {
+				got = append(got, string(c.Severity)+" "+c.Element+" "+c.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeWorkspace writes files (slash paths) into a temporary directory and makes it the working
+// directory for the rest of the test.
+func writeWorkspace(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := writeFileMkdir(filepath.Join(dir, filepath.FromSlash(name)), []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return dir
+}
+
+func TestBreakingAgainstExport(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"cfg.yaml": "import:\n  dir: proto\n  keep:\n    files:\n      - file: game/a\nexport:\n  language: go\n  dir: out\n  descriptorSet: out/manifest.pb\n  descriptorJson: out/manifest.json\n",
+		"proto/game/a.proto": `syntax = "proto3";
+package game;
+message Player {
+  int32 id = 1;
+  string nick = 3;
+  reserved 2;
+}
+`,
+	})
+	e := &Exporter{ConfigPath: "cfg.yaml"}
+	if err := e.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename("out", "prev"); err != nil {
+		t.Fatal(err)
+	}
+	next := `syntax = "proto3";
+package game;
+message Player {
+  int32 id = 1;
+  int64 reused = 2;
+  reserved 3;
+}
+`
+	if err := os.WriteFile(filepath.FromSlash("proto/game/a.proto"), []byte(next), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"info: out/a.proto: game.Player.nick: 字段 (3) 已删除，编号已保留",
+		"error: out/a.proto: game.Player.reused: 重用了已保留的编号 2",
+	}
+	// 上次导出的目录与其写出的清单（描述符集）得到相同结果
+	for _, against := range []string{"prev", "prev/manifest.pb", "prev/manifest.json"} {
+		changes, err := e.Breaking(against)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range changes {
+			got = append(got, c.String())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("against %s: changes =\n%q\nwant\n%q", against, got, want)
+		}
+	}
+	if _, err := os.Stat("out"); err == nil {
+		t.Error("breaking wrote the export directory")
+	}
+}
@@ -2,68 +2,123 @@ package converter
 
 import "strings"
 
-func toCamel(s string) string {
-	s = strings.TrimSpace(s)
-	// 全大写直接返回
-	if s == strings.ToUpper(s) {
+// caseStyle is a naming style plus the acronyms (ID, URL, ...) that camel styles keep upper-case.
+type caseStyle struct {
+	Kind     string
+	Acronyms []string
+}
+
+func (c caseStyle) keep() bool {
+	k := strings.ToLower(strings.TrimSpace(c.Kind))
+	return k == "" || k == "keep"
+}
+
+func (c caseStyle) apply(s string) string {
+	if c.keep() {
 		return s
 	}
-	// 首字母大写且后续全大写也直接返回（如 ASNBe）
-	if len(s) > 1 && isUpper(rune(s[0])) && s[1:] == strings.ToUpper(s[1:]) {
-		return s
+	words := splitWords(s, c.Acronyms)
+	switch strings.ToLower(strings.TrimSpace(c.Kind)) {
+	case "camel":
+		return c.joinCamel(words, false)
+	case "lowercamel":
+		return c.joinCamel(words, true)
+	case "snake":
+		return strings.ToLower(strings.Join(words, "_"))
+	case "screaming_snake":
+		return strings.ToUpper(strings.Join(words, "_"))
+	case "kebab":
+		return strings.ToLower(strings.Join(words, "-"))
+	case "compact":
+		return strings.ToLower(strings.Join(words, ""))
 	}
-	// 分隔符分段，每段首字母大写
-	parts := strings.FieldsFunc(s, isDelim)
-	for i, p := range parts {
-		if p == "" {
+	return s
+}
+
+// joinCamel capitalizes the first letter of each word and keeps the rest as written (ASNBe stays
+// ASNBe); dictionary acronyms take their dictionary form, and lower makes the first word lower-case.
+func (c caseStyle) joinCamel(words []string, lower bool) string {
+	var b strings.Builder
+	for i, w := range words {
+		if lower && i == 0 {
+			b.WriteString(strings.ToLower(w))
 			continue
 		}
-		runes := []rune(p)
-		if isLower(runes[0]) {
-			runes[0] = toUpper(runes[0])
+		if a, ok := c.acronym(w); ok {
+			b.WriteString(a)
+			continue
 		}
-		parts[i] = string(runes)
+		runes := []rune(w)
+		runes[0] = toUpper(runes[0])
+		b.WriteString(string(runes))
 	}
-	return strings.Join(parts, "")
+	return b.String()
 }
 
-func toSnake(s string) string {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return ""
+func (c caseStyle) acronym(w string) (string, bool) {
+	for _, a := range c.Acronyms {
+		if strings.EqualFold(a, w) {
+			return a, true
+		}
 	}
-	var result []rune
-	prevUnderscore := false
-	runes := []rune(s)
-	for i, r := range runes {
-		if isDelim(r) {
-			if !prevUnderscore {
-				result = append(result, '_')
-				prevUnderscore = true
+	return "", false
+}
+
+// splitWords segments an identifier into words: at delimiters, at lower/digit -> upper transitions
+// and before the last capital of an acronym run (HTTPServer -> HTTP Server, userID -> user ID).
+// An all-caps word made up of dictionary acronyms is split further (HTTPURL -> HTTP URL).
+func splitWords(s string, acronyms []string) []string {
+	var words []string
+	for _, chunk := range strings.FieldsFunc(strings.TrimSpace(s), isDelim) {
+		rs := []rune(chunk)
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			if !isUpper(rs[i]) {
+				continue
+			}
+			prev := rs[i-1]
+			if isLower(prev) || isDigit(prev) || (isUpper(prev) && i+1 < len(rs) && isLower(rs[i+1])) {
+				words = append(words, splitAcronyms(string(rs[start:i]), acronyms)...)
+				start = i
 			}
-			continue
 		}
+		words = append(words, splitAcronyms(string(rs[start:]), acronyms)...)
+	}
+	return words
+}
 
-		if isUpper(r) {
-			// 只要不是第一个字符且前一个不是下划线，就加下划线
-			if i > 0 && !prevUnderscore {
-				result = append(result, '_')
+// splitAcronyms splits an all-caps word that consists entirely of dictionary acronyms.
+func splitAcronyms(w string, acronyms []string) []string {
+	if len(acronyms) == 0 || len(w) < 2 || w != strings.ToUpper(w) {
+		return []string{w}
+	}
+	// next[i] 为从 i 开始可拆出的下一个缩写的结束位置，-1 表示无法拆分
+	next := make([]int, len(w)+1)
+	for i := len(w) - 1; i >= 0; i-- {
+		next[i] = -1
+		for _, a := range acronyms {
+			end := i + len(a)
+			if end <= len(w) && strings.EqualFold(w[i:end], a) && (end == len(w) || next[end] >= 0) && end > next[i] {
+				next[i] = end
 			}
-			result = append(result, toLower(r))
-			prevUnderscore = false
-		} else {
-			result = append(result, r)
-			prevUnderscore = false
 		}
 	}
-	res := string(result)
-	// 合并多余下划线
-	for strings.Contains(res, "__") {
-		res = strings.ReplaceAll(res, "__", "_")
+	if next[0] < 0 || next[0] == len(w) {
+		return []string{w}
+	}
+	var out []string
+	for i := 0; i < len(w); i = next[i] {
+		out = append(out, w[i:next[i]])
 	}
-	// 去除首尾下划线
-	res = strings.Trim(res, "_")
-	return res
+	return out
+}
+
+func toCamel(s string) string {
+	return caseStyle{Kind: "camel"}.apply(s)
+}
+
+func toSnake(s string) string {
+	return caseStyle{Kind: "snake"}.apply(s)
 }
 
 func isDigit(r rune) bool {
@@ -95,29 +150,3 @@ func toLower(r rune) rune {
 func isDelim(r rune) bool {
 	return r == '_' || r == '-' || r == '.' || r == ' '
 }
-
-func removeDelims(s string) string {
-	b := strings.Builder{}
-	for _, r := range s {
-		if isDelim(r) {
-			continue
-		}
-		b.WriteRune(r)
-	}
-	return b.String()
-}
-
-func toCase(s, caseKind string) string {
-	switch strings.ToLower(strings.TrimSpace(caseKind)) {
-	case "camel":
-		return toCamel(s)
-	case "snake":
-		return toSnake(s)
-	case "compact":
-		return strings.ToLower(removeDelims(s))
-	case "keep":
-		fallthrough
-	default:
-		return s
-	}
-}
@@ -34,8 +34,10 @@ func TestToSnake(t *testing.T) {
 	}{
 		{"FooBar", "foo_bar"},
 		{"fooBarBaz", "foo_bar_baz"},
-		{"FOOBar", "f_o_o_bar"},
-		{"fooBAR", "foo_b_a_r"},
+		{"FOOBar", "foo_bar"},
+		{"fooBAR", "foo_bar"},
+		{"HTTPServer", "http_server"},
+		{"userID", "user_id"},
 		{"foo_bar", "foo_bar"},
 		{"foo-bar", "foo_bar"},
 		{"foo.bar", "foo_bar"},
@@ -52,3 +54,29 @@ func TestToSnake(t *testing.T) {
 		}
 	}
 }
+
+func TestCaseStyle(t *testing.T) {
+	acr := []string{"ID", "URL", "UI", "HTTP"}
+	tests := []struct {
+		in, kind string
+		acronyms []string
+		out      string
+	}{
+		{"user_id", "camel", acr, "UserID"},
+		{"user_id", "camel", nil, "UserId"},
+		{"user_id", "lowerCamel", acr, "userID"},
+		{"IDValue", "lowerCamel", acr, "idValue"},
+		{"HTTPURLParser", "snake", acr, "http_url_parser"},
+		{"HTTPURLParser", "snake", nil, "httpurl_parser"},
+		{"ui_element", "camel", acr, "UIElement"},
+		{"HTTPServer", "kebab", nil, "http-server"},
+		{"userIdV2", "SCREAMING_SNAKE", nil, "USER_ID_V2"},
+		{"fooBar", "compact", nil, "foobar"},
+		{"foo_bar", "keep", acr, "foo_bar"},
+	}
+	for _, tt := range tests {
+		if got := (caseStyle{Kind: tt.kind, Acronyms: tt.acronyms}).apply(tt.in); got != tt.out {
+			t.Errorf("caseStyle{%s, %v}.apply(%q) = %q, want %q", tt.kind, tt.acronyms, tt.in, got, tt.out)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package converter
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	yaml "gopkg.in/yaml.v3"
@@ -22,64 +22,215 @@ type TypeRule struct {
 }
 
 type ImportKeep struct {
-	Files []FileRule `yaml:"files"`
-	Types []TypeRule `yaml:"types"`
+	// Include lists YAML files whose files/types are appended to this section.
+	Include []string   `yaml:"include"`
+	Files   []FileRule `yaml:"files"`
+	Types   []TypeRule `yaml:"types"`
 }
 
 type ImportSection struct {
-	Dir   string     `yaml:"dir"`
-	Prune *bool      `yaml:"prune"`
-	Keep  ImportKeep `yaml:"keep"`
+	Dir string `yaml:"dir" path:"true"`
+	// DescriptorSet reads a binary FileDescriptorSet instead of scanning Dir.
+	DescriptorSet string     `yaml:"descriptorSet" path:"true"`
+	Prune         *bool      `yaml:"prune"`
+	Keep          ImportKeep `yaml:"keep"`
 }
 
 type ExportSection struct {
-	Dir           string `yaml:"dir"`
-	Language      string `yaml:"language"`
-	Namespace     string `yaml:"namespace"`
-	FileNameCase  string `yaml:"fileNameCase"`
-	FieldNameCase string `yaml:"fieldNameCase"`
+	Dir       string `yaml:"dir" path:"true"`
+	Language  string `yaml:"language" enum:"language"`
+	Namespace string `yaml:"namespace"`
+	// NamespaceMap maps a proto package to its namespace, taking precedence over namespace.
+	NamespaceMap map[string]string `yaml:"namespaceMap"`
+	// DeriveNamespace derives the namespace from the proto package (game.shared -> Game.Shared)
+	// under NamespacePrefix when the package is not in NamespaceMap.
+	DeriveNamespace bool   `yaml:"deriveNamespace"`
+	NamespacePrefix string `yaml:"namespacePrefix"`
+	// PackageRewrite renames proto packages (longest prefix match) in package lines and references.
+	PackageRewrite map[string]string `yaml:"packageRewrite"`
+	// FlattenPackage puts every exported definition into this single package.
+	FlattenPackage string `yaml:"flattenPackage"`
+	FileNameCase   string `yaml:"fileNameCase" enum:"fileCase"`
+	FieldNameCase  string `yaml:"fieldNameCase" enum:"case"`
+	// TypeNameCase applies to message, enum and service names, nested ones included.
+	TypeNameCase string `yaml:"typeNameCase" enum:"case"`
+	// EnumValueCase applies to enum values; StripEnumPrefix first drops the enum-name prefix
+	// (Color.COLOR_RED -> RED).
+	EnumValueCase   string `yaml:"enumValueCase" enum:"case"`
+	StripEnumPrefix bool   `yaml:"stripEnumPrefix"`
+	// Acronyms lists words that camel styles keep in this form (ID, URL, UI) and that split
+	// runs of capitals (HTTPURL -> HTTP URL).
+	Acronyms []string `yaml:"acronyms"`
+	// PreserveJSONName adds json_name with the original JSON name to every renamed field.
+	PreserveJSONName bool `yaml:"preserveJsonName"`
+	// StripOptions removes the custom options of these namespaces (validate, gogoproto), both
+	// their uses and their definitions.
+	StripOptions []string `yaml:"stripOptions"`
+	// Rename renames types, fields and enum values; references are updated accordingly.
+	Rename RenameRules `yaml:"rename"`
+	// Grouping decides how definitions are split into output files: source (one per source
+	// file, default), package, definition or bundle (a single BundleName file).
+	Grouping   string `yaml:"grouping" enum:"grouping"`
+	BundleName string `yaml:"bundleName"`
+	// DescriptorSet writes a binary FileDescriptorSet of the outputs and their imports, built
+	// without protoc; DescriptorSourceInfo adds declaration spans, DescriptorJSON a protojson copy.
+	DescriptorSet        string `yaml:"descriptorSet" path:"true"`
+	DescriptorSourceInfo bool   `yaml:"descriptorSourceInfo"`
+	DescriptorJSON       string `yaml:"descriptorJson" path:"true"`
+	// JSONSchema is a directory receiving a JSON Schema (draft 2020-12) per exported message,
+	// following the proto3 JSON mapping.
+	JSONSchema string `yaml:"jsonSchema" path:"true"`
+	// Facade names an extra output file that `import public`s every other output.
+	Facade string `yaml:"facade"`
+	// FileOptions adds file options to every output (optimize_for: LITE_RUNTIME), overriding
+	// generated and source values.
+	FileOptions map[string]string `yaml:"fileOptions"`
+	// LanguageOptions sets language file options (java/objc/php/ruby/swift/go) explicitly.
+	LanguageOptions LangOptions `yaml:"languageOptions"`
+	// Keep overrides import.keep for this target; non-empty files/types replace the shared lists.
+	Keep *ImportKeep `yaml:"keep"`
+}
+
+// LintSection configures the lint subcommand.
+type LintSection struct {
+	// Format is text (default) or json.
+	Format string `yaml:"format" enum:"lintFormat"`
+	// Sources and Output select what is linted: the source files reachable from the seeds and
+	// the exported files. Both default to true.
+	Sources *bool     `yaml:"sources"`
+	Output  *bool     `yaml:"output"`
+	Rules   LintRules `yaml:"rules"`
+}
+
+// LintRules enables or disables each lint rule; rules left unset are enabled.
+type LintRules struct {
+	FieldNumberDuplicate *bool `yaml:"fieldNumberDuplicate"`
+	// FieldNumberRange flags numbers outside 1..536870911 and in 19000-19999.
+	FieldNumberRange    *bool `yaml:"fieldNumberRange"`
+	EnumZeroValue       *bool `yaml:"enumZeroValue"`
+	EnumValuePrefix     *bool `yaml:"enumValuePrefix"`
+	NamingConvention    *bool `yaml:"namingConvention"`
+	DuplicateDefinition *bool `yaml:"duplicateDefinition"`
+	MapKeyType          *bool `yaml:"mapKeyType"`
 }
 
 type Config struct {
-	DryRun *bool         `yaml:"dryRun"`
-	Import ImportSection `yaml:"import"`
-	Export ExportSection `yaml:"export"`
+	// Extends names a base config that this file is deep-merged over.
+	Extends string          `yaml:"extends"`
+	DryRun  *bool           `yaml:"dryRun"`
+	Import  ImportSection   `yaml:"import"`
+	Export  ExportSection   `yaml:"export"`
+	Exports []ExportSection `yaml:"exports"`
+	Lint    LintSection     `yaml:"lint"`
 }
 
-func readProtoConfig(path string) (cfg Config, seeds []protoItem, seedKeep map[string]map[string]struct{}, typeFieldKeep map[string]map[string]struct{}, err error) {
-	data, err := os.ReadFile(path)
+// keepRules is the resolved form of an ImportKeep section.
+type keepRules struct {
+	Seeds         []protoItem
+	SeedKeep      map[string]map[string]struct{}
+	TypeFieldKeep map[string]map[string]struct{}
+	Types         []TypeRule
+}
+
+// readProtoConfig loads the config at path and applies the "path=value" overrides in sets.
+func readProtoConfig(path string, env, sets []string) (Config, error) {
+	root, err := loadConfigNode(path, nil)
 	if err != nil {
-		return Config{}, nil, nil, nil, fmt.Errorf("无法打开配置 %s: %w", path, err)
+		return Config{}, err
+	}
+	if len(env)+len(sets) > 0 {
+		if root == nil {
+			root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		// 环境变量先于 --set 写入，同一配置项以 --set 为准
+		for _, s := range env {
+			if err := applySet(root, s); err != nil {
+				return Config{}, err
+			}
+		}
+		for _, s := range sets {
+			if err := applySet(root, s); err != nil {
+				return Config{}, err
+			}
+		}
+		if err := validateNode("--set", root, reflect.TypeOf(Config{})); err != nil {
+			return Config{}, err
+		}
 	}
-
 	var c Config
-	if err := yaml.Unmarshal(data, &c); err != nil {
-		return Config{}, nil, nil, nil, fmt.Errorf("配置解析失败: %w", err)
+	if root != nil {
+		layerExports(root)
+		if err := root.Decode(&c); err != nil {
+			return Config{}, fmt.Errorf("配置解析失败: %w", err)
+		}
 	}
 
 	// 校验配置
-	if c.Export.Language == "" && c.Export.Dir == "" && c.Import.Dir == "" && len(c.Import.Keep.Files) == 0 && len(c.Import.Keep.Types) == 0 && c.Import.Prune == nil && c.DryRun == nil {
-		return Config{}, nil, nil, nil, fmt.Errorf("仅支持 import/export 结构配置：请参考模板 export_*_proto.yaml")
+	if c.Export.Language == "" && c.Export.Dir == "" && len(c.Exports) == 0 && c.Import.Dir == "" && c.Import.DescriptorSet == "" && len(c.Import.Keep.Files) == 0 && len(c.Import.Keep.Types) == 0 && c.Import.Prune == nil && c.DryRun == nil {
+		return Config{}, fmt.Errorf("仅支持 import/export 结构配置：请参考模板 export_*_proto.yaml")
 	}
+	return c, nil
+}
+
+// targets returns the export sections to run: the exports entries, or export alone.
+// Each exports entry has already been merged over export by layerExports.
+func (c Config) targets() []ExportSection {
+	if len(c.Exports) == 0 {
+		return []ExportSection{c.Export}
+	}
+	return c.Exports
+}
+
+// layerExports deep-merges every exports entry over the export section, so entries only
+// need to state what differs.
+func layerExports(root *yaml.Node) {
+	base := mapValue(root, "export")
+	list := mapValue(root, "exports")
+	if base == nil || base.Kind != yaml.MappingNode || list == nil || list.Kind != yaml.SequenceNode {
+		return
+	}
+	for i, x := range list.Content {
+		list.Content[i] = mergeNodes(base, x)
+	}
+}
 
+// keepFor returns import.keep with the per-target override applied.
+func (c Config) keepFor(t ExportSection) ImportKeep {
+	k := c.Import.Keep
+	if t.Keep != nil {
+		if len(t.Keep.Files) > 0 {
+			k.Files = t.Keep.Files
+		}
+		if len(t.Keep.Types) > 0 {
+			k.Types = t.Keep.Types
+		}
+	}
+	return k
+}
+
+func buildKeepRules(k ImportKeep) (keepRules, error) {
 	var fileList []string
 	rawKeep := map[string][]string{}
-	for _, fr := range c.Import.Keep.Files {
+	for _, fr := range k.Files {
 		t := strings.TrimSpace(fr.File)
 		if t == "" {
 			continue
 		}
 		fileList = append(fileList, t)
-		rawKeep[t] = fr.Keep
+		// 与 SeedsFromList 一致：省略扩展名时补 .proto，保证 keep 能按种子路径查到
+		if !strings.HasSuffix(strings.ToLower(t), ".proto") {
+			t += ".proto"
+		}
+		rawKeep[t] = append(rawKeep[t], fr.Keep...)
 	}
 	if len(fileList) == 0 {
-		return Config{}, nil, nil, nil, fmt.Errorf("配置 files 为空: 需要至少一个种子文件")
+		return keepRules{}, fmt.Errorf("配置 files 为空: 需要至少一个种子文件")
 	}
 	sd, err := (SeedLoader{}).SeedsFromList(fileList)
 	if err != nil {
-		return Config{}, nil, nil, nil, err
+		return keepRules{}, err
 	}
-	seedKeep = map[string]map[string]struct{}{}
+	seedKeep := map[string]map[string]struct{}{}
 	for _, it := range sd {
 		key := filepath.ToSlash(it.Path)
 		cands := []string{key, filepath.ToSlash(it.Base), filepath.ToSlash(filepath.Join(it.Dir, it.Base))}
@@ -99,8 +250,8 @@ func readProtoConfig(path string) (cfg Config, seeds []protoItem, seedKeep map[s
 		}
 	}
 
-	typeFieldKeep = map[string]map[string]struct{}{}
-	for _, tr := range c.Import.Keep.Types {
+	typeFieldKeep := map[string]map[string]struct{}{}
+	for _, tr := range k.Types {
 		tname := strings.TrimSpace(tr.Type)
 		if tname == "" {
 			continue
@@ -116,5 +267,5 @@ func readProtoConfig(path string) (cfg Config, seeds []protoItem, seedKeep map[s
 			typeFieldKeep[tname] = set
 		}
 	}
-	return c, sd, seedKeep, typeFieldKeep, nil
+	return keepRules{Seeds: sd, SeedKeep: seedKeep, TypeFieldKeep: typeFieldKeep, Types: k.Types}, nil
 }
@@ -0,0 +1,330 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// loadConfigNode 读取配置文件并展开 extends/include 与环境变量，返回合并后的映射节点。
+// extends、include 以及带 path 标签的字段（import.dir、export.dir 等）中的相对路径，
+// 均以声明它们的文件所在目录为基准。
+func loadConfigNode(path string, stack []string) (*yaml.Node, error) {
+	root, err := readYAMLFile(path, reflect.TypeOf(Config{}), stack)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, nil
+	}
+	dir := filepath.Dir(path)
+	rebasePaths(root, reflect.TypeOf(Config{}), workRel(dir))
+	if err := expandIncludes(root, reflect.TypeOf(Config{}), dir, append(stack, path)); err != nil {
+		return nil, err
+	}
+	ext := takeKey(root, "extends")
+	if ext == nil || ext.Value == "" {
+		return root, nil
+	}
+	base, err := loadConfigNode(resolveRel(dir, ext.Value), append(stack, path))
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return root, nil
+	}
+	return mergeNodes(base, root), nil
+}
+
+// readYAMLFile 解析并严格校验单个 YAML 文件，完成环境变量插值；空文件返回 nil。
+func readYAMLFile(path string, t reflect.Type, stack []string) (*yaml.Node, error) {
+	for _, p := range stack {
+		if p == path {
+			return nil, fmt.Errorf("配置存在循环引用: %s -> %s", strings.Join(stack, " -> "), path)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开配置 %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("配置解析失败 %s: %w", path, err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if err := interpolateEnv(path, root); err != nil {
+		return nil, err
+	}
+	if err := validateNode(path, &doc, t); err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	return root, nil
+}
+
+// expandIncludes 把 ImportKeep 中 include 引用的文件内容追加到 files/types 列表。
+func expandIncludes(n *yaml.Node, t reflect.Type, dir string, stack []string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case n == nil:
+		return nil
+	case t == reflect.TypeOf(ImportKeep{}) && n.Kind == yaml.MappingNode:
+		inc := takeKey(n, "include")
+		if inc == nil {
+			return nil
+		}
+		for _, p := range inc.Content {
+			file := resolveRel(dir, p.Value)
+			sub, err := readYAMLFile(file, t, stack)
+			if err != nil {
+				return err
+			}
+			if sub == nil {
+				continue
+			}
+			if err := expandIncludes(sub, t, filepath.Dir(file), append(stack, file)); err != nil {
+				return err
+			}
+			for _, key := range []string{"files", "types"} {
+				if add := mapValue(sub, key); add != nil && add.Kind == yaml.SequenceNode {
+					dst := mapValue(n, key)
+					if dst == nil || dst.Kind != yaml.SequenceNode {
+						dst = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+						setKey(n, key, dst)
+					}
+					dst.Content = append(dst.Content, add.Content...)
+				}
+			}
+		}
+		return nil
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if f, ok := fields[n.Content[i].Value]; ok {
+				if err := expandIncludes(n.Content[i+1], f.Type, dir, stack); err != nil {
+					return err
+				}
+			}
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := expandIncludes(c, t.Elem(), dir, stack); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rebasePaths 把 n 中带 path 标签的相对路径改写为以 dir 为基准。
+func rebasePaths(n *yaml.Node, t reflect.Type, dir string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case n == nil:
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			f, ok := fields[n.Content[i].Value]
+			if !ok {
+				continue
+			}
+			if v := n.Content[i+1]; f.Tag.Get("path") != "" && v.Kind == yaml.ScalarNode && v.Value != "" && v.Tag != "!!null" {
+				v.Value = filepath.ToSlash(resolveRel(dir, v.Value))
+				continue
+			}
+			rebasePaths(n.Content[i+1], f.Type, dir)
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, c := range n.Content {
+			rebasePaths(c, t.Elem(), dir)
+		}
+	}
+}
+
+// workRel 把工作目录之内的目录表示为相对路径，使改写后的路径与命令行给出的路径形式一致；
+// 工作目录之外的目录保持绝对路径。
+func workRel(dir string) string {
+	if !filepath.IsAbs(dir) {
+		return dir
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+	}
+	return dir
+}
+
+// mergeNodes 深度合并：映射逐键合并，列表与标量由 over 覆盖 base。
+func mergeNodes(base, over *yaml.Node) *yaml.Node {
+	if base == nil || base.Kind != yaml.MappingNode || over.Kind != yaml.MappingNode {
+		return over
+	}
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: base.Tag, Line: base.Line, Column: base.Column}
+	out.Content = append(out.Content, base.Content...)
+	for i := 0; i+1 < len(over.Content); i += 2 {
+		k, v := over.Content[i], over.Content[i+1]
+		if prev := mapValue(out, k.Value); prev != nil {
+			setKey(out, k.Value, mergeNodes(prev, v))
+			continue
+		}
+		out.Content = append(out.Content, k, v)
+	}
+	return out
+}
+
+var envRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolateEnv 替换字符串值中的 ${VAR} 与 ${VAR:-default}。
+func interpolateEnv(file string, n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		if n.Tag != "!!str" && n.Tag != "" || !strings.Contains(n.Value, "${") {
+			return nil
+		}
+		var missing []string
+		n.Value = envRe.ReplaceAllStringFunc(n.Value, func(m string) string {
+			g := envRe.FindStringSubmatch(m)
+			v, ok := os.LookupEnv(g[1])
+			if g[2] != "" && v == "" {
+				return g[3]
+			}
+			if !ok {
+				missing = append(missing, g[1])
+			}
+			return v
+		})
+		if len(missing) > 0 {
+			return fmt.Errorf("%s:%d:%d: 环境变量未设置且无默认值: %s", file, n.Line, n.Column, strings.Join(missing, ", "))
+		}
+		// 未加引号的值按替换后的内容重新推断类型，使 ${DRY_RUN:-false} 之类可用于布尔字段
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+		return nil
+	}
+	for i, c := range n.Content {
+		// 映射的键不参与插值
+		if n.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		if err := interpolateEnv(file, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setKey(n *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = v
+			return
+		}
+	}
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v)
+}
+
+// takeKey removes key from the mapping node and returns its value.
+func takeKey(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			v := n.Content[i+1]
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return v
+		}
+	}
+	return nil
+}
+
+func resolveRel(dir, p string) string {
+	p = filepath.FromSlash(p)
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// applySet 把形如 export.namespace=Game 或 exports[0].dir=out 的覆盖写入配置节点，值按 YAML 解析。
+// export.<key> 的覆盖同时移除各 exports 项中的该键，使其经 export 合并后作用于每个导出目标；
+// 路径类配置项（如 export.dir）在有多个导出目标时会让它们写到同一处，因此拒绝。
+func applySet(root *yaml.Node, expr string) error {
+	eq := strings.Index(expr, "=")
+	if eq <= 0 {
+		return fmt.Errorf("无效的覆盖 %q: 需要 path=value", expr)
+	}
+	path, raw := strings.TrimSpace(expr[:eq]), expr[eq+1:]
+	var val yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &val); err != nil {
+		return fmt.Errorf("无效的覆盖 %q: %w", expr, err)
+	}
+	if key, ok := strings.CutPrefix(path, "export."); ok && !strings.ContainsAny(key, ".[") {
+		if list := mapValue(root, "exports"); list != nil && list.Kind == yaml.SequenceNode {
+			if f, ok := yamlFields(reflect.TypeOf(ExportSection{}))[key]; ok && f.Tag.Get("path") != "" && len(list.Content) > 1 {
+				return fmt.Errorf("无效的覆盖 %q: %d 个导出目标会写到同一路径，请改用 exports[i].%s", expr, len(list.Content), key)
+			}
+			for _, x := range list.Content {
+				if x.Kind == yaml.MappingNode {
+					takeKey(x, key)
+				}
+			}
+		}
+	}
+	v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: ""}
+	if len(val.Content) > 0 {
+		v = val.Content[0]
+	}
+	segs := strings.Split(strings.NewReplacer("[", ".", "]", "").Replace(path), ".")
+	cur := root
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		if idx, err := strconv.Atoi(seg); err == nil {
+			if cur.Kind != yaml.SequenceNode || idx < 0 || idx >= len(cur.Content) {
+				return fmt.Errorf("无效的覆盖 %q: %s 超出列表范围", expr, seg)
+			}
+			if last {
+				cur.Content[idx] = v
+				return nil
+			}
+			cur = cur.Content[idx]
+			continue
+		}
+		if cur.Kind != yaml.MappingNode {
+			return fmt.Errorf("无效的覆盖 %q: %s 不是映射", expr, seg)
+		}
+		if last {
+			setKey(cur, seg, v)
+			return nil
+		}
+		next := mapValue(cur, seg)
+		if next == nil || next.Tag == "!!null" {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setKey(cur, seg, next)
+		}
+		cur = next
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadProtoConfigExtends(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("base/base.yaml", "import:\n  dir: proto\n  keep:\n    include: [keep.yaml]\nexport:\n  language: csharp\n  namespace: ${PC_TEST_NS:-Base.Ns}\n  dir: out\n  jsonSchema: schema\n")
+	write("base/keep.yaml", "files:\n  - file: a\ntypes:\n  - type: A\n    keep: [x]\n")
+	write("child.yaml", "extends: base/base.yaml\nimport:\n  keep:\n    files:\n      - file: b\nexport:\n  dir: out/${PC_TEST_DIR}\n")
+	t.Setenv("PC_TEST_DIR", "ios")
+
+	cfg, err := readProtoConfig(filepath.Join(dir, "child.yaml"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 路径以声明它们的配置文件所在目录为基准
+	if cfg.Import.Dir != filepath.ToSlash(filepath.Join(dir, "base", "proto")) || cfg.Export.Language != "csharp" || cfg.Export.Namespace != "Base.Ns" || cfg.Export.Dir != filepath.ToSlash(filepath.Join(dir, "out", "ios")) {
+		t.Errorf("merged config = %+v", cfg)
+	}
+	if want := filepath.ToSlash(filepath.Join(dir, "base", "schema")); cfg.Export.JSONSchema != want {
+		t.Errorf("Export.JSONSchema = %q, want %q", cfg.Export.JSONSchema, want)
+	}
+	// 列表由子配置整体覆盖；include 追加的 types 保留
+	if got := cfg.Import.Keep.Files; !reflect.DeepEqual(got, []FileRule{{File: "b"}}) {
+		t.Errorf("Import.Keep.Files = %v", got)
+	}
+	if got := cfg.Import.Keep.Types; !reflect.DeepEqual(got, []TypeRule{{Type: "A", Keep: []string{"x"}}}) {
+		t.Errorf("Import.Keep.Types = %v", got)
+	}
+
+	write("missing.yaml", "export:\n  dir: ${PC_TEST_UNSET_VAR}\n")
+	if _, err := readProtoConfig(filepath.Join(dir, "missing.yaml"), nil, nil); err == nil {
+		t.Error("expected error for unset environment variable")
+	}
+}
+
+func TestReadProtoConfigPaths(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"cfg/app.yaml": "import:\n  dir: proto\nexport:\n  language: csharp\n  dir: out\n  descriptorSet: /abs/set.pb\n",
+	})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 绝对与相对的配置路径得到相同结果：工作目录之内的路径写成相对形式
+	for _, path := range []string{"cfg/app.yaml", filepath.Join(wd, "cfg", "app.yaml")} {
+		cfg, err := readProtoConfig(path, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Import.Dir != "cfg/proto" || cfg.Export.Dir != "cfg/out" || cfg.Export.DescriptorSet != "/abs/set.pb" {
+			t.Errorf("readProtoConfig(%s): import.dir %q, export.dir %q, descriptorSet %q", path, cfg.Import.Dir, cfg.Export.Dir, cfg.Export.DescriptorSet)
+		}
+	}
+}
+
+func TestReadProtoConfigOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.yaml")
+	data := "export:\n  language: csharp\n  namespace: Base\nexports:\n  - dir: a\n    namespace: Own\n  - dir: b\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		env, sets []string
+		want      []string
+	}{
+		{"config", nil, nil, []string{"Own", "Base"}},
+		{"env reaches every target", []string{`export.namespace="Env"`}, nil, []string{"Env", "Env"}},
+		{"set beats env", []string{`export.namespace="Env"`}, []string{"exports[1].namespace=Set"}, []string{"Env", "Set"}},
+		{"set on export beats env", []string{`export.namespace="Env"`}, []string{"export.namespace=Set"}, []string{"Set", "Set"}},
+		{"set on export reaches every target", nil, []string{"export.namespace=Set"}, []string{"Set", "Set"}},
+		{"set on exports entry after export", nil, []string{"export.namespace=Set", "exports[0].namespace=Own"}, []string{"Own", "Set"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := readProtoConfig(path, tt.env, tt.sets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, sec := range cfg.targets() {
+				got = append(got, sec.Namespace)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("namespaces = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	// 多个导出目标不能共用同一输出路径
+	for _, o := range []string{"export.dir=out", "export.jsonSchema=schema"} {
+		if _, err := readProtoConfig(path, nil, []string{o}); err == nil {
+			t.Errorf("readProtoConfig accepted --set %s with two exports", o)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// enumSets 列出配置中枚举型字段的可选值，字段通过 `enum:"<name>"` 标签引用。
+var enumSets = map[string][]string{
+	"case":       {"keep", "camel", "lowerCamel", "snake", "SCREAMING_SNAKE"},
+	"fileCase":   {"keep", "camel", "lowerCamel", "snake", "SCREAMING_SNAKE", "kebab", "compact"},
+	"language":   languageNames(),
+	"grouping":   {"source", "package", "definition", "bundle"},
+	"lintFormat": {"text", "json"},
+}
+
+func enumAllowed(set, v string) bool {
+	v = strings.TrimSpace(v)
+	for _, a := range enumSets[set] {
+		if strings.EqualFold(v, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNode 按目标结构 t 严格校验 YAML：拒绝未知字段，并检查枚举值。
+func validateNode(file string, node *yaml.Node, t reflect.Type) error {
+	var errs []string
+	walkConfigNode(node, t, "", func(n *yaml.Node, msg string) {
+		errs = append(errs, fmt.Sprintf("%s:%d:%d: %s", file, n.Line, n.Column, msg))
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("配置校验失败:\n  %s", strings.Join(errs, "\n  "))
+}
+
+func walkConfigNode(n *yaml.Node, t reflect.Type, path string, report func(*yaml.Node, string)) {
+	if n == nil || n.Kind == 0 {
+		return
+	}
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			walkConfigNode(c, t, path, report)
+		}
+		return
+	}
+	if n.Kind == yaml.AliasNode {
+		walkConfigNode(n.Alias, t, path, report)
+		return
+	}
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			report(n, fmt.Sprintf("%s 应为映射", displayPath(path)))
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			f, ok := fields[k.Value]
+			if !ok {
+				report(k, fmt.Sprintf("未知字段 %q (位于 %s)", k.Value, displayPath(path)))
+				continue
+			}
+			sub := joinPath(path, k.Value)
+			if set := f.Tag.Get("enum"); set != "" && v.Kind == yaml.ScalarNode && v.Tag != "!!null" {
+				if !enumAllowed(set, v.Value) {
+					report(v, fmt.Sprintf("%s 的取值 %q 无效 (可选: %s)", sub, v.Value, strings.Join(enumSets[set], "/")))
+				}
+				continue
+			}
+			walkConfigNode(v, f.Type, sub, report)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			report(n, fmt.Sprintf("%s 应为列表", displayPath(path)))
+			return
+		}
+		for i, c := range n.Content {
+			walkConfigNode(c, t.Elem(), fmt.Sprintf("%s[%d]", path, i), report)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			report(n, fmt.Sprintf("%s 应为映射", displayPath(path)))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			walkConfigNode(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value), report)
+		}
+	default:
+		if n.Kind != yaml.ScalarNode {
+			report(n, fmt.Sprintf("%s 应为标量值", displayPath(path)))
+		}
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	out := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		out[name] = f
+	}
+	return out
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "根节点"
+	}
+	return path
+}
+
+// ConfigJSONSchema generates a JSON Schema for the YAML config from the Config struct.
+func ConfigJSONSchema() ([]byte, error) {
+	s := typeSchema(reflect.TypeOf(Config{}), "")
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "proto-converter config"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func typeSchema(t reflect.Type, enumSet string) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		for name, f := range yamlFields(t) {
+			props[name] = typeSchema(f.Type, f.Tag.Get("enum"))
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), enumSet)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), enumSet)}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	default:
+		s := map[string]any{"type": "string"}
+		if enumSet != "" {
+			s["enum"] = enumSets[enumSet]
+		}
+		return s
+	}
+}
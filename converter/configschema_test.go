@@ -0,0 +1,50 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestValidateNode(t *testing.T) {
+	tests := []struct {
+		in   string
+		errs []string
+	}{
+		{"export:\n  language: csharp\n  fileNameCase: snake\n", nil},
+		{"exprot:\n  dir: out\n", []string{`cfg.yaml:1:1: 未知字段 "exprot"`}},
+		{"export:\n  fileNameCasee: camel\n", []string{`cfg.yaml:2:3: 未知字段 "fileNameCasee" (位于 export)`}},
+		{"export:\n  fieldNameCase: pascal\n", []string{`cfg.yaml:2:18: export.fieldNameCase 的取值 "pascal" 无效`}},
+		{"export:\n  language: cobol\n", []string{`export.language 的取值 "cobol" 无效`}},
+		{"export:\n  fileNameCase: kebab\n  fieldNameCase: SCREAMING_SNAKE\n", nil},
+		{"export:\n  fieldNameCase: kebab\n", []string{`cfg.yaml:2:18: export.fieldNameCase 的取值 "kebab" 无效`}},
+		{"export:\n  typeNameCase: compact\n", []string{`export.typeNameCase 的取值 "compact" 无效`}},
+		{"exports:\n  - enumValueCase: kebab\n", []string{`exports[0].enumValueCase 的取值 "kebab" 无效`}},
+		{"import:\n  keep:\n    files:\n      - file: a\n        keeps: [X]\n", []string{`cfg.yaml:5:9: 未知字段 "keeps" (位于 import.keep.files[0])`}},
+		{"import:\n  keep:\n    files:\n    types:\n", nil},
+	}
+	for _, tt := range tests {
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(tt.in), &root); err != nil {
+			t.Fatalf("yaml.Unmarshal(%q): %v", tt.in, err)
+		}
+		err := validateNode("cfg.yaml", &root, reflect.TypeOf(Config{}))
+		if len(tt.errs) == 0 {
+			if err != nil {
+				t.Errorf("validateNode(%q) = %v, want nil", tt.in, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("validateNode(%q) = nil, want error", tt.in)
+			continue
+		}
+		for _, want := range tt.errs {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("validateNode(%q) = %v, want it to contain %q", tt.in, err, want)
+			}
+		}
+	}
+}
@@ -10,8 +10,9 @@ import (
 type DepResolver struct{}
 
 // CollectWithImportsAndRoots resolves seeds to actual files and returns the transitive
-// closure of imported proto files along with the resolved seed items.
-func (DepResolver) CollectWithImportsAndRoots(seeds []protoItem, importDir string) ([]protoItem, []protoItem, error) {
+// closure of imported proto files, the resolved seed items (aligned with seeds) and the
+// import graph keyed by slash-separated path.
+func (DepResolver) CollectWithImportsAndRoots(seeds []protoItem, importDir string) ([]protoItem, []protoItem, map[string][]string, error) {
 	roots := []string{}
 	for _, it := range seeds {
 		if it.Dir != "" {
@@ -56,14 +57,16 @@ func (DepResolver) CollectWithImportsAndRoots(seeds []protoItem, importDir strin
 	roots = uniq(roots)
 
 	seen := map[string]protoItem{}
+	deps := map[string][]string{}
 	var queue []protoItem
-	push := func(it protoItem) {
+	push := func(it protoItem) protoItem {
 		key := strings.ToLower(it.Base)
-		if _, ok := seen[key]; ok {
-			return
+		if prev, ok := seen[key]; ok {
+			return prev
 		}
 		seen[key] = it
 		queue = append(queue, it)
+		return it
 	}
 	var resolvedSeeds []protoItem
 	for _, it := range seeds {
@@ -111,9 +114,9 @@ func (DepResolver) CollectWithImportsAndRoots(seeds []protoItem, importDir strin
 		if err != nil {
 			continue
 		}
-		matches := importRe.FindAllStringSubmatch(string(data), -1)
-		for _, m := range matches {
-			imp := strings.TrimSpace(m[1])
+		// public 与 weak import 同样跟随
+		for _, m := range parseImports(stripComments(string(data))) {
+			imp := m.Path
 			var found string
 			if cur.Dir != "" {
 				p := filepath.Join(cur.Dir, imp)
@@ -134,7 +137,8 @@ func (DepResolver) CollectWithImportsAndRoots(seeds []protoItem, importDir strin
 				continue
 			}
 			if it, err := normalizeItem(found); err == nil {
-				push(it)
+				dep := push(it)
+				deps[shortPath(cur.Path)] = append(deps[shortPath(cur.Path)], shortPath(dep.Path))
 			}
 		}
 	}
@@ -150,5 +154,30 @@ func (DepResolver) CollectWithImportsAndRoots(seeds []protoItem, importDir strin
 			}
 		}
 	}
-	return out, resolvedSeeds, nil
+	return out, resolvedSeeds, deps, nil
+}
+
+// closureOf returns the items reachable from seeds through the import graph, in the order of all.
+func closureOf(all []protoItem, seeds []protoItem, deps map[string][]string) []protoItem {
+	reach := map[string]struct{}{}
+	var walk func(p string)
+	walk = func(p string) {
+		if _, ok := reach[p]; ok {
+			return
+		}
+		reach[p] = struct{}{}
+		for _, d := range deps[p] {
+			walk(d)
+		}
+	}
+	for _, s := range seeds {
+		walk(shortPath(s.Path))
+	}
+	out := make([]protoItem, 0, len(reach))
+	for _, it := range all {
+		if _, ok := reach[shortPath(it.Path)]; ok {
+			out = append(out, it)
+		}
+	}
+	return out
 }
@@ -0,0 +1,109 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// loadDescriptorSet reads a binary FileDescriptorSet (protoc --descriptor_set_out) and links it.
+// Custom options come back as extension fields so they can be rendered by name.
+func loadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取描述符集失败: %w", err)
+	}
+	raw := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, raw); err != nil {
+		return nil, fmt.Errorf("%s: 不是有效的 FileDescriptorSet: %w", path, err)
+	}
+	files := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, fd := range raw.File {
+		if _, ok := files[fd.GetName()]; ok {
+			return nil, fmt.Errorf("%s: 文件 %s 重复出现", path, fd.GetName())
+		}
+		files[fd.GetName()] = fd
+	}
+	// 未随集合提供的依赖取自 well-known 文件，由 fallbackResolver 查找
+	reg := &protoregistry.Files{}
+	for _, fd := range orderFiles(files) {
+		f, err := protodesc.NewFile(fd, fallbackResolver{reg})
+		if err != nil {
+			return nil, fmt.Errorf("%s: 描述符校验失败: %w", path, err)
+		}
+		if err := reg.RegisterFile(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := (proto.UnmarshalOptions{Resolver: dynamicpb.NewTypes(reg)}).Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	set.File = orderFiles(indexFiles(set.File))
+	return set, nil
+}
+
+// renderDescriptorSet writes every non well-known file of set as .proto text under dir,
+// keeping the import paths recorded in the descriptors.
+func renderDescriptorSet(set *descriptorpb.FileDescriptorSet, dir string) error {
+	for _, fd := range set.File {
+		if strings.HasPrefix(fd.GetName(), "google/protobuf/") {
+			continue
+		}
+		if err := writeFileMkdir(filepath.Join(dir, filepath.FromSlash(fd.GetName())), []byte(renderProto(fd))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importFromDescriptorSet renders the descriptor set at path into a temporary directory that
+// then serves as import.dir. The caller removes the directory.
+func importFromDescriptorSet(path string) (string, error) {
+	set, err := loadDescriptorSet(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "proto-converter-ds-")
+	if err != nil {
+		return "", err
+	}
+	if err := renderDescriptorSet(set, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+func indexFiles(list []*descriptorpb.FileDescriptorProto) map[string]*descriptorpb.FileDescriptorProto {
+	out := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, fd := range list {
+		out[fd.GetName()] = fd
+	}
+	return out
+}
+
+// fallbackResolver resolves against the files linked so far, then the well-known files.
+type fallbackResolver struct{ local *protoregistry.Files }
+
+func (r fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.local.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.local.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
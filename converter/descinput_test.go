@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+const legacyProto = `syntax = "proto2";
+package legacy;
+message Item {
+  required string id = 1;
+  optional int32 count = 2 [default = -3];
+  optional string note = 3 [default = "a\"b\n"];
+  optional double ratio = 4 [default = inf, packed = false];
+  repeated group Tag = 5 { optional string name = 1; }
+  extensions 100 to max;
+  reserved 7;
+}
+extend Item { optional bool flag = 100; }
+enum Kind {
+  option allow_alias = true;
+  KIND_A = 1;
+  KIND_B = 1 [deprecated = true];
+}
+`
+
+func TestDescriptorSetRoundTrip(t *testing.T) {
+	sources := map[string]string{"demo.proto": demoProto, "legacy.proto": legacyProto}
+	for name, src := range optionSources {
+		sources[name] = src
+	}
+	want, _, err := buildDescriptorSet(sources, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := proto.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	pb := filepath.Join(tmp, "in.pb")
+	if err := os.WriteFile(pb, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := loadDescriptorSet(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, "out")
+	if err := renderDescriptorSet(set, dir); err != nil {
+		t.Fatal(err)
+	}
+	rendered := map[string]string{}
+	for name := range sources {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered[name] = string(data)
+	}
+	got, _, err := buildDescriptorSet(rendered, false)
+	if err != nil {
+		t.Fatalf("rendered protos do not compile: %v\n%v", err, rendered)
+	}
+	if !proto.Equal(got, want) {
+		for i := range want.File {
+			if i < len(got.File) && !proto.Equal(got.File[i], want.File[i]) {
+				t.Errorf("%s changed after round trip\ngot:  %v\nwant: %v\nsource:\n%s", want.File[i].GetName(),
+					prototext.Format(got.File[i]), prototext.Format(want.File[i]), rendered[want.File[i].GetName()])
+			}
+		}
+		t.Fatalf("files = %v, want %v", fileNames(got), fileNames(want))
+	}
+}
+
+func TestLoadDescriptorSetErrors(t *testing.T) {
+	tmp := t.TempDir()
+	bad := filepath.Join(tmp, "bad.pb")
+	if err := os.WriteFile(bad, []byte("not a descriptor"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadDescriptorSet(bad); err == nil {
+		t.Error("invalid descriptor set accepted")
+	}
+	if _, err := loadDescriptorSet(filepath.Join(tmp, "missing.pb")); err == nil {
+		t.Error("missing descriptor set accepted")
+	}
+}
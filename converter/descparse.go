@@ -0,0 +1,1359 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// descToken is one lexical token of proto source; string tokens hold the unescaped value.
+type descToken struct {
+	kind byte // 'i' 标识符、'n' 数字、's' 字符串，其余为标点本身，0 表示结束
+	text string
+	line int
+	col  int
+	end  int // 结束列（不含），用于 source info
+}
+
+// lexProto splits proto source into tokens, skipping whitespace and comments.
+func lexProto(src string) ([]descToken, error) {
+	var toks []descToken
+	line, col := 0, 0
+	advance := func(n int, i int) int {
+		for k := 0; k < n; k++ {
+			if src[i+k] == '\n' {
+				line, col = line+1, 0
+			} else {
+				col++
+			}
+		}
+		return i + n
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case isSpace(c):
+			i = advance(1, i)
+		case strings.HasPrefix(src[i:], "//"):
+			j := strings.IndexByte(src[i:], '\n')
+			if j < 0 {
+				j = len(src) - i
+			}
+			i = advance(j, i)
+		case strings.HasPrefix(src[i:], "/*"):
+			j := strings.Index(src[i+2:], "*/")
+			if j < 0 {
+				return nil, fmt.Errorf("%d:%d: 注释未闭合", line+1, col+1)
+			}
+			i = advance(j+4, i)
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && isIdent(src[j]) {
+				j++
+			}
+			toks = append(toks, descToken{kind: 'i', text: src[i:j], line: line, col: col, end: col + j - i})
+			i = advance(j-i, i)
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			hex := strings.HasPrefix(strings.ToLower(src[i:]), "0x")
+			j := i
+			for j < len(src) && (isIdent(src[j]) || src[j] == '.' || !hex && (src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			toks = append(toks, descToken{kind: 'n', text: src[i:j], line: line, col: col, end: col + j - i})
+			i = advance(j-i, i)
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c && src[j] != '\n' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) || src[j] != c {
+				return nil, fmt.Errorf("%d:%d: 字符串未闭合", line+1, col+1)
+			}
+			s, err := unescapeProto(src[i+1 : j])
+			if err != nil {
+				return nil, fmt.Errorf("%d:%d: %v", line+1, col+1, err)
+			}
+			toks = append(toks, descToken{kind: 's', text: s, line: line, col: col, end: col + j + 1 - i})
+			i = advance(j+1-i, i)
+		default:
+			toks = append(toks, descToken{kind: c, text: string(c), line: line, col: col, end: col + 1})
+			i = advance(1, i)
+		}
+	}
+	return append(toks, descToken{line: line, col: col, end: col}), nil
+}
+
+// unescapeProto decodes the escape sequences of a proto string literal body.
+func unescapeProto(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("无效的转义")
+		}
+		switch c := s[i]; c {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x', 'X':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHex(s[j]) {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("无效的转义 \\%s", s[i:j])
+			}
+			b.WriteByte(byte(v))
+			i = j - 1
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", fmt.Errorf("无效的转义 \\%c", c)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("无效的转义 \\%s", s[i:i+1+n])
+			}
+			b.WriteRune(rune(v))
+			i += n
+		default:
+			if c >= '0' && c <= '7' {
+				j := i
+				for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(s[i:j], 8, 8)
+				b.WriteByte(byte(v))
+				i = j - 1
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func isHex(c byte) bool { return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' }
+
+// optionPart is one segment of an option name; Ext marks a parenthesized extension name.
+type optionPart struct {
+	Name string
+	Ext  bool
+}
+
+// optionValue is the literal on the right of an option; aggregates keep their text format body.
+type optionValue struct {
+	Kind byte // 'i' 标识符、'n' 数字、's' 字符串、'{' 聚合
+	Text string
+	Neg  bool
+}
+
+// pendingOption is a custom option left for interpretation once the files are linked.
+type pendingOption struct {
+	Opts  proto.Message
+	Scope string
+	Name  []optionPart
+	Value optionValue
+	Pos   string
+}
+
+// typeRef is a type name to resolve against scope; Field is set when the reference is a field type.
+type typeRef struct {
+	Name  *string
+	Scope string
+	Field *descriptorpb.FieldDescriptorProto
+	Pos   string
+}
+
+// descParser builds a FileDescriptorProto from the tokens of one proto file.
+type descParser struct {
+	name    string
+	toks    []descToken
+	pos     int
+	fd      *descriptorpb.FileDescriptorProto
+	locs    []*descriptorpb.SourceCodeInfo_Location
+	refs    []typeRef
+	pending []pendingOption
+}
+
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "float": descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int64": descriptorpb.FieldDescriptorProto_TYPE_INT64, "uint64": descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int32": descriptorpb.FieldDescriptorProto_TYPE_INT32, "fixed64": descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32": descriptorpb.FieldDescriptorProto_TYPE_FIXED32, "bool": descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING, "bytes": descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"uint32": descriptorpb.FieldDescriptorProto_TYPE_UINT32, "sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64, "sint32": descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64": descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// parseDescriptor parses proto source into a FileDescriptorProto named name. Type names are left
+// for the returned references, custom options for the returned pending list.
+func parseDescriptor(name, src string) (*descParser, error) {
+	toks, err := lexProto(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", name, err)
+	}
+	p := &descParser{name: name, toks: toks, fd: &descriptorpb.FileDescriptorProto{Name: proto.String(name)}}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *descParser) peek(n int) descToken {
+	if p.pos+n < len(p.toks) {
+		return p.toks[p.pos+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *descParser) next() descToken {
+	t := p.peek(0)
+	if p.pos < len(p.toks)-1 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *descParser) at(t descToken) string {
+	return fmt.Sprintf("%s:%d:%d", p.name, t.line+1, t.col+1)
+}
+
+func (p *descParser) errorf(t descToken, format string, args ...any) error {
+	return fmt.Errorf("%s: %s", p.at(t), fmt.Sprintf(format, args...))
+}
+
+func (p *descParser) is(text string) bool {
+	t := p.peek(0)
+	return t.kind != 's' && t.kind != 0 && t.text == text
+}
+
+func (p *descParser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *descParser) expect(text string) (descToken, error) {
+	t := p.peek(0)
+	if !p.is(text) {
+		return t, p.errorf(t, "期望 %q，实际为 %q", text, t.text)
+	}
+	return p.next(), nil
+}
+
+func (p *descParser) ident() (string, error) {
+	t := p.next()
+	if t.kind != 'i' {
+		return "", p.errorf(t, "期望标识符，实际为 %q", t.text)
+	}
+	return t.text, nil
+}
+
+// fullIdent reads a possibly dotted (and leading-dot) name.
+func (p *descParser) fullIdent() (string, error) {
+	var b strings.Builder
+	if p.accept(".") {
+		b.WriteByte('.')
+	}
+	for {
+		id, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(id)
+		if !p.is(".") {
+			return b.String(), nil
+		}
+		p.next()
+		b.WriteByte('.')
+	}
+}
+
+func (p *descParser) str() (string, error) {
+	t := p.next()
+	if t.kind != 's' {
+		return "", p.errorf(t, "期望字符串，实际为 %q", t.text)
+	}
+	s := t.text
+	for p.peek(0).kind == 's' {
+		s += p.next().text
+	}
+	return s, nil
+}
+
+func (p *descParser) integer() (int64, error) {
+	t := p.peek(0)
+	neg := p.accept("-")
+	n := p.next()
+	if n.kind != 'n' {
+		return 0, p.errorf(n, "期望整数，实际为 %q", n.text)
+	}
+	v, err := strconv.ParseInt(n.text, 0, 64)
+	if err != nil {
+		return 0, p.errorf(t, "无效的整数 %q", n.text)
+	}
+	if neg {
+		v = -v
+	}
+	return v, nil
+}
+
+// loc records a source location for path spanning tokens from start to the previous token.
+func (p *descParser) loc(path []int32, start descToken) {
+	end := p.toks[p.pos-1]
+	span := []int32{int32(start.line), int32(start.col), int32(end.line), int32(end.end)}
+	if start.line == end.line {
+		span = []int32{int32(start.line), int32(start.col), int32(end.end)}
+	}
+	p.locs = append(p.locs, &descriptorpb.SourceCodeInfo_Location{Path: append([]int32(nil), path...), Span: span})
+}
+
+func (p *descParser) ref(name *string, scope string, field *descriptorpb.FieldDescriptorProto, t descToken) {
+	p.refs = append(p.refs, typeRef{Name: name, Scope: scope, Field: field, Pos: p.at(t)})
+}
+
+func (p *descParser) parseFile() error {
+	fd := p.fd
+	scope := ""
+	for p.peek(0).kind != 0 {
+		start := p.peek(0)
+		switch {
+		case p.accept(";"):
+		case p.accept("syntax"):
+			if _, err := p.expect("="); err != nil {
+				return err
+			}
+			s, err := p.str()
+			if err != nil {
+				return err
+			}
+			if s == "proto3" {
+				fd.Syntax = proto.String(s)
+			} else if s != "proto2" {
+				return p.errorf(start, "不支持的 syntax %q", s)
+			}
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.accept("edition"):
+			if _, err := p.expect("="); err != nil {
+				return err
+			}
+			s, err := p.str()
+			if err != nil {
+				return err
+			}
+			ed, ok := descriptorpb.Edition_value["EDITION_"+s]
+			if !ok {
+				return p.errorf(start, "不支持的 edition %q", s)
+			}
+			fd.Syntax, fd.Edition = proto.String("editions"), descriptorpb.Edition(ed).Enum()
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.accept("package"):
+			pkg, err := p.fullIdent()
+			if err != nil {
+				return err
+			}
+			fd.Package, scope = proto.String(pkg), "."+pkg
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.accept("import"):
+			idx := int32(len(fd.Dependency))
+			if p.accept("public") {
+				fd.PublicDependency = append(fd.PublicDependency, idx)
+			} else if p.accept("weak") {
+				fd.WeakDependency = append(fd.WeakDependency, idx)
+			}
+			s, err := p.str()
+			if err != nil {
+				return err
+			}
+			fd.Dependency = append(fd.Dependency, s)
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.accept("option"):
+			if fd.Options == nil {
+				fd.Options = &descriptorpb.FileOptions{}
+			}
+			if err := p.optionStmt(fd.Options, scope); err != nil {
+				return err
+			}
+		case p.is("message"):
+			p.next()
+			m, err := p.message(scope, []int32{4, int32(len(fd.MessageType))}, start)
+			if err != nil {
+				return err
+			}
+			fd.MessageType = append(fd.MessageType, m)
+		case p.is("enum"):
+			p.next()
+			e, err := p.enum(scope, []int32{5, int32(len(fd.EnumType))}, start)
+			if err != nil {
+				return err
+			}
+			fd.EnumType = append(fd.EnumType, e)
+		case p.is("service"):
+			p.next()
+			s, err := p.service(scope, []int32{6, int32(len(fd.Service))}, start)
+			if err != nil {
+				return err
+			}
+			fd.Service = append(fd.Service, s)
+		case p.is("extend"):
+			p.next()
+			if err := p.extend(scope, &fd.Extension, []int32{7}, &fd.MessageType, []int32{4}); err != nil {
+				return err
+			}
+		default:
+			return p.errorf(start, "无法识别的语句 %q", start.text)
+		}
+	}
+	return nil
+}
+
+func (p *descParser) syntax() string {
+	if s := p.fd.GetSyntax(); s != "" {
+		return s
+	}
+	return "proto2"
+}
+
+// optionStmt parses `name = value;` after the option keyword into opts.
+func (p *descParser) optionStmt(opts proto.Message, scope string) error {
+	if err := p.option(opts, scope); err != nil {
+		return err
+	}
+	_, err := p.expect(";")
+	return err
+}
+
+// option parses one `name = value` and applies it: built-in options at once, custom ones later.
+func (p *descParser) option(opts proto.Message, scope string) error {
+	start := p.peek(0)
+	var name []optionPart
+	for {
+		if p.accept("(") {
+			n, err := p.fullIdent()
+			if err != nil {
+				return err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return err
+			}
+			name = append(name, optionPart{Name: n, Ext: true})
+		} else {
+			n, err := p.ident()
+			if err != nil {
+				return err
+			}
+			name = append(name, optionPart{Name: n})
+		}
+		if !p.accept(".") {
+			break
+		}
+	}
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	v, err := p.optionValue()
+	if err != nil {
+		return err
+	}
+	if name[0].Ext {
+		p.pending = append(p.pending, pendingOption{Opts: opts, Scope: scope, Name: name, Value: v, Pos: p.at(start)})
+		return nil
+	}
+	if err := setOptionPath(opts.ProtoReflect(), name, v); err != nil {
+		return p.errorf(start, "%v", err)
+	}
+	return nil
+}
+
+func (p *descParser) optionValue() (optionValue, error) {
+	t := p.peek(0)
+	switch {
+	case t.kind == 's':
+		s, err := p.str()
+		return optionValue{Kind: 's', Text: s}, err
+	case t.kind == '{':
+		text, err := p.aggregate()
+		return optionValue{Kind: '{', Text: text}, err
+	case t.kind == '-' || t.kind == '+':
+		p.next()
+		n := p.next()
+		if n.kind != 'n' && n.kind != 'i' {
+			return optionValue{}, p.errorf(n, "期望数字，实际为 %q", n.text)
+		}
+		return optionValue{Kind: n.kind, Text: n.text, Neg: t.kind == '-'}, nil
+	case t.kind == 'n' || t.kind == 'i':
+		p.next()
+		return optionValue{Kind: t.kind, Text: t.text}, nil
+	}
+	return optionValue{}, p.errorf(t, "无效的 option 取值 %q", t.text)
+}
+
+// aggregate reads a `{ ... }` literal and returns its body re-rendered in text format.
+func (p *descParser) aggregate() (string, error) {
+	open := p.next()
+	var parts []string
+	for depth := 1; ; {
+		t := p.next()
+		switch t.kind {
+		case 0:
+			return "", p.errorf(open, "聚合值未闭合")
+		case '{', '<':
+			depth++
+		case '}', '>':
+			depth--
+		}
+		if depth == 0 {
+			return strings.Join(parts, " "), nil
+		}
+		if t.kind == 's' {
+			parts = append(parts, strconv.Quote(t.text))
+		} else {
+			parts = append(parts, t.text)
+		}
+	}
+}
+
+// setOptionPath sets a built-in option (possibly nested, e.g. features.field_presence) on m.
+func setOptionPath(m protoreflect.Message, name []optionPart, v optionValue) error {
+	for i, part := range name {
+		if part.Ext {
+			return fmt.Errorf("option %s 中的扩展须写在最前", joinOptionName(name))
+		}
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(part.Name))
+		if fd == nil {
+			return fmt.Errorf("未知的 option %s", joinOptionName(name))
+		}
+		if i < len(name)-1 {
+			if fd.Message() == nil || fd.IsList() {
+				return fmt.Errorf("option %s 不是消息类型", joinOptionName(name[:i+1]))
+			}
+			m = m.Mutable(fd).Message()
+			continue
+		}
+		return setOptionField(m, fd, v)
+	}
+	return nil
+}
+
+// setOptionField assigns v to field fd of m, appending to repeated fields.
+func setOptionField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v optionValue) error {
+	if fd.Message() != nil {
+		return fmt.Errorf("option %s 需要聚合值", fd.FullName())
+	}
+	val, err := scalarOptionValue(fd, v)
+	if err != nil {
+		return err
+	}
+	if fd.IsList() {
+		m.Mutable(fd).List().Append(val)
+		return nil
+	}
+	m.Set(fd, val)
+	return nil
+}
+
+// scalarOptionValue converts an option literal to the value of a scalar or enum field.
+func scalarOptionValue(fd protoreflect.FieldDescriptor, v optionValue) (protoreflect.Value, error) {
+	bad := fmt.Errorf("option %s 的取值 %q 类型不匹配", fd.FullName(), v.Text)
+	sign := ""
+	if v.Neg {
+		sign = "-"
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if v.Kind != 'i' || (v.Text != "true" && v.Text != "false") {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfBool(v.Text == "true"), nil
+	case protoreflect.EnumKind:
+		if v.Kind != 'i' {
+			return protoreflect.Value{}, bad
+		}
+		ev := fd.Enum().Values().ByName(protoreflect.Name(v.Text))
+		if ev == nil {
+			return protoreflect.Value{}, fmt.Errorf("枚举 %s 没有值 %s", fd.Enum().FullName(), v.Text)
+		}
+		return protoreflect.ValueOfEnum(ev.Number()), nil
+	case protoreflect.StringKind:
+		if v.Kind != 's' {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfString(v.Text), nil
+	case protoreflect.BytesKind:
+		if v.Kind != 's' {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfBytes([]byte(v.Text)), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f, err := parseProtoFloat(sign+v.Text, v.Kind)
+		if err != nil {
+			return protoreflect.Value{}, bad
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(sign+v.Text, 0, 32)
+		if err != nil || v.Kind != 'n' {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(sign+v.Text, 0, 64)
+		if err != nil || v.Kind != 'n' {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(v.Text, 0, 32)
+		if err != nil || v.Kind != 'n' || v.Neg {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(v.Text, 0, 64)
+		if err != nil || v.Kind != 'n' || v.Neg {
+			return protoreflect.Value{}, bad
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	}
+	return protoreflect.Value{}, bad
+}
+
+func parseProtoFloat(s string, kind byte) (float64, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, "-")) {
+	case "inf", "infinity":
+		if strings.HasPrefix(s, "-") {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	if kind != 'n' {
+		return 0, fmt.Errorf("无效的浮点数 %q", s)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func joinOptionName(name []optionPart) string {
+	parts := make([]string, len(name))
+	for i, p := range name {
+		parts[i] = p.Name
+		if p.Ext {
+			parts[i] = "(" + p.Name + ")"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// message parses `Name { ... }` after the message keyword.
+func (p *descParser) message(scope string, path []int32, start descToken) (*descriptorpb.DescriptorProto, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	if err := p.messageBody(m, scope+"."+name, path); err != nil {
+		return nil, err
+	}
+	p.loc(path, start)
+	return m, nil
+}
+
+// messageBody parses `{ ... }` into m; scope is the message's full name with a leading dot.
+func (p *descParser) messageBody(m *descriptorpb.DescriptorProto, scope string, path []int32) error {
+	if _, err := p.expect("{"); err != nil {
+		return err
+	}
+	sub := func(field int32, i int) []int32 { return append(append([]int32(nil), path...), field, int32(i)) }
+	for !p.accept("}") {
+		start := p.peek(0)
+		switch {
+		case start.kind == 0:
+			return p.errorf(start, "消息 %s 未闭合", m.GetName())
+		case p.accept(";"):
+		case p.accept("option"):
+			if m.Options == nil {
+				m.Options = &descriptorpb.MessageOptions{}
+			}
+			if err := p.optionStmt(m.Options, scope); err != nil {
+				return err
+			}
+		case p.is("message") && p.peek(1).kind == 'i' && p.peek(2).kind == '{':
+			p.next()
+			nm, err := p.message(scope, sub(3, len(m.NestedType)), start)
+			if err != nil {
+				return err
+			}
+			m.NestedType = append(m.NestedType, nm)
+		case p.is("enum") && p.peek(1).kind == 'i' && p.peek(2).kind == '{':
+			p.next()
+			e, err := p.enum(scope, sub(4, len(m.EnumType)), start)
+			if err != nil {
+				return err
+			}
+			m.EnumType = append(m.EnumType, e)
+		case p.is("extend") && p.peek(2).kind != '=':
+			p.next()
+			if err := p.extend(scope, &m.Extension, append(append([]int32(nil), path...), 6), &m.NestedType, append(append([]int32(nil), path...), 3)); err != nil {
+				return err
+			}
+		case p.is("oneof") && p.peek(1).kind == 'i' && p.peek(2).kind == '{':
+			p.next()
+			if err := p.oneof(m, scope, path, start); err != nil {
+				return err
+			}
+		case p.is("extensions") && p.peek(1).kind == 'n':
+			p.next()
+			if err := p.extensionRanges(m, scope); err != nil {
+				return err
+			}
+		case p.is("reserved") && (p.peek(1).kind == 'n' || p.peek(1).kind == 's' || p.peek(1).kind == 'i' && p.peek(2).kind != '='):
+			p.next()
+			if err := p.reserved(&m.ReservedName, func(lo, hi int32) {
+				m.ReservedRange = append(m.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{Start: proto.Int32(lo), End: proto.Int32(hi + 1)})
+			}); err != nil {
+				return err
+			}
+		default:
+			f, err := p.field(m, scope, sub(2, len(m.Field)), sub(3, len(m.NestedType)), nil, true)
+			if err != nil {
+				return err
+			}
+			m.Field = append(m.Field, f)
+		}
+	}
+	// proto3 optional 字段各自位于一个合成 oneof 中，排在真实 oneof 之后
+	for _, f := range m.Field {
+		if f.GetProto3Optional() {
+			f.OneofIndex = proto.Int32(int32(len(m.OneofDecl)))
+			m.OneofDecl = append(m.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.GetName())})
+		}
+	}
+	return nil
+}
+
+// field parses a field, map field or group. Groups and map entries are appended to nested;
+// oneofIdx is set for oneof members, which have no label.
+func (p *descParser) field(m *descriptorpb.DescriptorProto, scope string, path, nestedPath []int32, oneofIdx *int32, labeled bool) (*descriptorpb.FieldDescriptorProto, error) {
+	start := p.peek(0)
+	f := &descriptorpb.FieldDescriptorProto{OneofIndex: oneofIdx}
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	explicit := false
+	if labeled {
+		switch {
+		case p.accept("optional"):
+			explicit = true
+		case p.accept("required"):
+			label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+		case p.accept("repeated"):
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+	}
+	f.Label = label.Enum()
+	switch {
+	case p.is("map") && p.peek(1).kind == '<':
+		p.next()
+		p.next()
+		key, err := p.fullIdent()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(","); err != nil {
+			return nil, err
+		}
+		valTok := p.peek(0)
+		val, err := p.fullIdent()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		if err := p.fieldTail(f, scope); err != nil {
+			return nil, err
+		}
+		entry := &descriptorpb.DescriptorProto{
+			Name:    proto.String(mapEntryName(f.GetName())),
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+		entryScope := scope + "." + entry.GetName()
+		for i, kv := range []string{key, val} {
+			ef := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String([]string{"key", "value"}[i]),
+				Number:   proto.Int32(int32(i + 1)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				JsonName: proto.String([]string{"key", "value"}[i]),
+			}
+			if typ, ok := scalarTypes[kv]; ok {
+				ef.Type = typ.Enum()
+			} else {
+				ef.TypeName = proto.String(kv)
+				p.ref(ef.TypeName, entryScope, ef, valTok)
+			}
+			entry.Field = append(entry.Field, ef)
+		}
+		m.NestedType = append(m.NestedType, entry)
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		f.TypeName = proto.String(entryScope)
+	case p.is("group") && p.peek(1).kind == 'i' && p.peek(2).kind == '=':
+		p.next()
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("="); err != nil {
+			return nil, err
+		}
+		num, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		f.Name, f.Number = proto.String(strings.ToLower(name)), proto.Int32(int32(num))
+		f.JsonName = proto.String(jsonCamel(f.GetName()))
+		f.Type, f.TypeName = descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(), proto.String(scope+"."+name)
+		if p.is("[") {
+			if err := p.fieldOptions(f, scope); err != nil {
+				return nil, err
+			}
+		}
+		g := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+		if err := p.messageBody(g, scope+"."+name, nestedPath); err != nil {
+			return nil, err
+		}
+		p.loc(nestedPath, start)
+		m.NestedType = append(m.NestedType, g)
+		p.loc(path, start)
+		return f, nil
+	default:
+		typTok := p.peek(0)
+		typ, err := p.fullIdent()
+		if err != nil {
+			return nil, err
+		}
+		if st, ok := scalarTypes[typ]; ok {
+			f.Type = st.Enum()
+		} else {
+			f.TypeName = proto.String(typ)
+			p.ref(f.TypeName, scope, f, typTok)
+		}
+		if err := p.fieldTail(f, scope); err != nil {
+			return nil, err
+		}
+	}
+	if explicit && p.syntax() == "proto3" {
+		f.Proto3Optional = proto.Bool(true)
+	}
+	p.loc(path, start)
+	return f, nil
+}
+
+// fieldTail parses `name = number [options];`.
+func (p *descParser) fieldTail(f *descriptorpb.FieldDescriptorProto, scope string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	num, err := p.integer()
+	if err != nil {
+		return err
+	}
+	f.Name, f.Number = proto.String(name), proto.Int32(int32(num))
+	f.JsonName = proto.String(jsonCamel(name))
+	if p.is("[") {
+		if err := p.fieldOptions(f, scope); err != nil {
+			return err
+		}
+	}
+	_, err = p.expect(";")
+	return err
+}
+
+// fieldOptions parses `[a = b, ...]`, handling the default and json_name pseudo-options.
+func (p *descParser) fieldOptions(f *descriptorpb.FieldDescriptorProto, scope string) error {
+	p.next()
+	for {
+		switch {
+		case p.is("default") && p.peek(1).kind == '=':
+			p.next()
+			p.next()
+			v, err := p.optionValue()
+			if err != nil {
+				return err
+			}
+			f.DefaultValue = proto.String(defaultText(f, v))
+		case p.is("json_name") && p.peek(1).kind == '=':
+			p.next()
+			p.next()
+			s, err := p.str()
+			if err != nil {
+				return err
+			}
+			f.JsonName = proto.String(s)
+		default:
+			if f.Options == nil {
+				f.Options = &descriptorpb.FieldOptions{}
+			}
+			if err := p.option(f.Options, scope); err != nil {
+				return err
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	_, err := p.expect("]")
+	return err
+}
+
+// defaultText renders a default value the way protoc stores it in default_value.
+func defaultText(f *descriptorpb.FieldDescriptorProto, v optionValue) string {
+	sign := ""
+	if v.Neg {
+		sign = "-"
+	}
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return v.Text
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return cEscape(v.Text)
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		if x, err := parseProtoFloat(sign+v.Text, v.Kind); err == nil {
+			switch {
+			case math.IsInf(x, 1):
+				return "inf"
+			case math.IsInf(x, -1):
+				return "-inf"
+			case math.IsNaN(x):
+				return "nan"
+			}
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		}
+	default:
+		if v.Kind == 'n' {
+			if n, err := strconv.ParseInt(sign+v.Text, 0, 64); err == nil {
+				return strconv.FormatInt(n, 10)
+			}
+			if n, err := strconv.ParseUint(v.Text, 0, 64); err == nil {
+				return strconv.FormatUint(n, 10)
+			}
+		}
+	}
+	return sign + v.Text
+}
+
+// cEscape escapes bytes like protoc's CEscape for bytes default values.
+func cEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '"' || c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// jsonCamel is protoc's default JSON name: underscores removed, the following letter capitalized.
+func jsonCamel(name string) string {
+	var b strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_':
+			upper = true
+		case upper && c >= 'a' && c <= 'z':
+			b.WriteByte(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteByte(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// mapEntryName is the synthesized entry message name of a map field (my_map -> MyMapEntry).
+func mapEntryName(field string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range field {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String() + "Entry"
+}
+
+func (p *descParser) oneof(m *descriptorpb.DescriptorProto, scope string, path []int32, start descToken) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	idx := int32(len(m.OneofDecl))
+	o := &descriptorpb.OneofDescriptorProto{Name: proto.String(name)}
+	m.OneofDecl = append(m.OneofDecl, o)
+	if _, err := p.expect("{"); err != nil {
+		return err
+	}
+	for !p.accept("}") {
+		switch {
+		case p.peek(0).kind == 0:
+			return p.errorf(start, "oneof %s 未闭合", name)
+		case p.accept(";"):
+		case p.accept("option"):
+			if o.Options == nil {
+				o.Options = &descriptorpb.OneofOptions{}
+			}
+			if err := p.optionStmt(o.Options, scope); err != nil {
+				return err
+			}
+		default:
+			fpath := append(append([]int32(nil), path...), 2, int32(len(m.Field)))
+			npath := append(append([]int32(nil), path...), 3, int32(len(m.NestedType)))
+			f, err := p.field(m, scope, fpath, npath, proto.Int32(idx), false)
+			if err != nil {
+				return err
+			}
+			m.Field = append(m.Field, f)
+		}
+	}
+	p.loc(append(append([]int32(nil), path...), 8, idx), start)
+	return nil
+}
+
+func (p *descParser) extensionRanges(m *descriptorpb.DescriptorProto, scope string) error {
+	first := len(m.ExtensionRange)
+	for {
+		lo, err := p.integer()
+		if err != nil {
+			return err
+		}
+		hi := lo
+		if p.accept("to") {
+			if p.accept("max") {
+				hi = 536870911
+			} else if hi, err = p.integer(); err != nil {
+				return err
+			}
+		}
+		m.ExtensionRange = append(m.ExtensionRange, &descriptorpb.DescriptorProto_ExtensionRange{Start: proto.Int32(int32(lo)), End: proto.Int32(int32(hi + 1))})
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.accept("[") {
+		opts := &descriptorpb.ExtensionRangeOptions{}
+		for {
+			if err := p.option(opts, scope); err != nil {
+				return err
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+		if _, err := p.expect("]"); err != nil {
+			return err
+		}
+		for _, r := range m.ExtensionRange[first:] {
+			r.Options = opts
+		}
+	}
+	_, err := p.expect(";")
+	return err
+}
+
+// reserved parses reserved ranges (via add, inclusive bounds) or names into names.
+func (p *descParser) reserved(names *[]string, add func(lo, hi int32)) error {
+	for {
+		switch t := p.peek(0); t.kind {
+		case 's':
+			s, err := p.str()
+			if err != nil {
+				return err
+			}
+			*names = append(*names, s)
+		case 'i':
+			*names = append(*names, p.next().text)
+		default:
+			lo, err := p.integer()
+			if err != nil {
+				return err
+			}
+			hi := lo
+			if p.accept("to") {
+				if p.accept("max") {
+					hi = 536870911
+				} else if hi, err = p.integer(); err != nil {
+					return err
+				}
+			}
+			add(int32(lo), int32(hi))
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	_, err := p.expect(";")
+	return err
+}
+
+// extend parses `Target { fields }`, appending fields to exts and groups to nested.
+func (p *descParser) extend(scope string, exts *[]*descriptorpb.FieldDescriptorProto, extPath []int32, nested *[]*descriptorpb.DescriptorProto, nestedPath []int32) error {
+	tt := p.peek(0)
+	target, err := p.fullIdent()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return err
+	}
+	holder := &descriptorpb.DescriptorProto{NestedType: *nested}
+	for !p.accept("}") {
+		if p.peek(0).kind == 0 {
+			return p.errorf(tt, "extend %s 未闭合", target)
+		}
+		if p.accept(";") {
+			continue
+		}
+		path := append(append([]int32(nil), extPath...), int32(len(*exts)))
+		npath := append(append([]int32(nil), nestedPath...), int32(len(holder.NestedType)))
+		f, err := p.field(holder, scope, path, npath, nil, true)
+		if err != nil {
+			return err
+		}
+		f.Extendee = proto.String(target)
+		p.ref(f.Extendee, scope, nil, tt)
+		*exts = append(*exts, f)
+	}
+	*nested = holder.NestedType
+	return nil
+}
+
+func (p *descParser) enum(scope string, path []int32, start descToken) (*descriptorpb.EnumDescriptorProto, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e := &descriptorpb.EnumDescriptorProto{Name: proto.String(name)}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.accept("}") {
+		vstart := p.peek(0)
+		switch {
+		case vstart.kind == 0:
+			return nil, p.errorf(start, "枚举 %s 未闭合", name)
+		case p.accept(";"):
+		case p.accept("option"):
+			if e.Options == nil {
+				e.Options = &descriptorpb.EnumOptions{}
+			}
+			if err := p.optionStmt(e.Options, scope); err != nil {
+				return nil, err
+			}
+		case p.is("reserved") && p.peek(1).kind != '=':
+			p.next()
+			if err := p.reserved(&e.ReservedName, func(lo, hi int32) {
+				e.ReservedRange = append(e.ReservedRange, &descriptorpb.EnumDescriptorProto_EnumReservedRange{Start: proto.Int32(lo), End: proto.Int32(hi)})
+			}); err != nil {
+				return nil, err
+			}
+		default:
+			vname, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("="); err != nil {
+				return nil, err
+			}
+			num, err := p.integer()
+			if err != nil {
+				return nil, err
+			}
+			v := &descriptorpb.EnumValueDescriptorProto{Name: proto.String(vname), Number: proto.Int32(int32(num))}
+			if p.accept("[") {
+				v.Options = &descriptorpb.EnumValueOptions{}
+				for {
+					if err := p.option(v.Options, scope); err != nil {
+						return nil, err
+					}
+					if !p.accept(",") {
+						break
+					}
+				}
+				if _, err := p.expect("]"); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			p.loc(append(append([]int32(nil), path...), 2, int32(len(e.Value))), vstart)
+			e.Value = append(e.Value, v)
+		}
+	}
+	p.loc(path, start)
+	return e, nil
+}
+
+func (p *descParser) service(scope string, path []int32, start descToken) (*descriptorpb.ServiceDescriptorProto, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	s := &descriptorpb.ServiceDescriptorProto{Name: proto.String(name)}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.accept("}") {
+		mstart := p.peek(0)
+		switch {
+		case mstart.kind == 0:
+			return nil, p.errorf(start, "服务 %s 未闭合", name)
+		case p.accept(";"):
+		case p.accept("option"):
+			if s.Options == nil {
+				s.Options = &descriptorpb.ServiceOptions{}
+			}
+			if err := p.optionStmt(s.Options, scope); err != nil {
+				return nil, err
+			}
+		case p.accept("rpc"):
+			m, err := p.method(scope)
+			if err != nil {
+				return nil, err
+			}
+			p.loc(append(append([]int32(nil), path...), 2, int32(len(s.Method))), mstart)
+			s.Method = append(s.Method, m)
+		default:
+			return nil, p.errorf(mstart, "无法识别的语句 %q", mstart.text)
+		}
+	}
+	p.loc(path, start)
+	return s, nil
+}
+
+func (p *descParser) method(scope string) (*descriptorpb.MethodDescriptorProto, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &descriptorpb.MethodDescriptorProto{Name: proto.String(name)}
+	for i, dst := range []**string{&m.InputType, &m.OutputType} {
+		if i == 1 {
+			if _, err := p.expect("returns"); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		if p.is("stream") && p.peek(1).kind != ')' {
+			p.next()
+			if i == 0 {
+				m.ClientStreaming = proto.Bool(true)
+			} else {
+				m.ServerStreaming = proto.Bool(true)
+			}
+		}
+		t := p.peek(0)
+		typ, err := p.fullIdent()
+		if err != nil {
+			return nil, err
+		}
+		*dst = proto.String(typ)
+		p.ref(*dst, scope, nil, t)
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("{") {
+		for !p.accept("}") {
+			switch {
+			case p.peek(0).kind == 0:
+				return nil, p.errorf(p.peek(0), "rpc %s 未闭合", name)
+			case p.accept(";"):
+			case p.accept("option"):
+				if m.Options == nil {
+					m.Options = &descriptorpb.MethodOptions{}
+				}
+				if err := p.optionStmt(m.Options, scope); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, p.errorf(p.peek(0), "无法识别的语句 %q", p.peek(0).text)
+			}
+		}
+		p.accept(";")
+		return m, nil
+	}
+	_, err = p.expect(";")
+	return m, err
+}
@@ -0,0 +1,503 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// renderProto renders a file descriptor back to proto source. Custom options are rendered
+// when their extensions were resolved while decoding the descriptor.
+func renderProto(fd *descriptorpb.FileDescriptorProto) string {
+	r := &protoRenderer{fd: fd, syntax: fd.GetSyntax()}
+	if r.syntax == "" {
+		r.syntax = "proto2"
+	}
+	var b strings.Builder
+	if r.syntax == "editions" {
+		fmt.Fprintf(&b, "edition = %q;\n\n", strings.TrimPrefix(fd.GetEdition().String(), "EDITION_"))
+	} else {
+		fmt.Fprintf(&b, "syntax = %q;\n\n", r.syntax)
+	}
+	if fd.GetPackage() != "" {
+		fmt.Fprintf(&b, "package %s;\n\n", fd.GetPackage())
+	}
+	for i, dep := range fd.Dependency {
+		kind := ""
+		if containsInt32(fd.PublicDependency, int32(i)) {
+			kind = "public "
+		} else if containsInt32(fd.WeakDependency, int32(i)) {
+			kind = "weak "
+		}
+		fmt.Fprintf(&b, "import %s%q;\n", kind, dep)
+	}
+	if len(fd.Dependency) > 0 {
+		b.WriteString("\n")
+	}
+	if opts := renderOptions(fd.Options); len(opts) > 0 {
+		for _, o := range opts {
+			b.WriteString("option " + o + ";\n")
+		}
+		b.WriteString("\n")
+	}
+	scope := ""
+	if fd.GetPackage() != "" {
+		scope = "." + fd.GetPackage()
+	}
+	var blocks []string
+	for _, m := range fd.MessageType {
+		if !r.isGroupType(scope, m.GetName(), fd.Extension) {
+			blocks = append(blocks, r.message(m, scope, ""))
+		}
+	}
+	for _, e := range fd.EnumType {
+		blocks = append(blocks, r.enum(e, ""))
+	}
+	if len(fd.Extension) > 0 {
+		blocks = append(blocks, r.extends(fd.Extension, scope, fd.MessageType, ""))
+	}
+	for _, s := range fd.Service {
+		blocks = append(blocks, r.service(s))
+	}
+	b.WriteString(strings.Join(blocks, "\n\n"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+type protoRenderer struct {
+	fd     *descriptorpb.FileDescriptorProto
+	syntax string
+}
+
+func containsInt32(list []int32, v int32) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName renders a fully qualified reference relative to the file's package when possible.
+func (r *protoRenderer) typeName(fqn string) string {
+	if pkg := r.fd.GetPackage(); pkg != "" && strings.HasPrefix(fqn, "."+pkg+".") {
+		return fqn[len(pkg)+2:]
+	}
+	return strings.TrimPrefix(fqn, ".")
+}
+
+// isGroupType reports whether the message scope.name is the body of a group field in fields.
+func (r *protoRenderer) isGroupType(scope, name string, fields []*descriptorpb.FieldDescriptorProto) bool {
+	if r.syntax == "editions" {
+		return false
+	}
+	for _, f := range fields {
+		if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_GROUP && f.GetTypeName() == scope+"."+name {
+			return true
+		}
+	}
+	return false
+}
+
+func findMessage(msgs []*descriptorpb.DescriptorProto, scope, fqn string) *descriptorpb.DescriptorProto {
+	for _, m := range msgs {
+		if scope+"."+m.GetName() == fqn {
+			return m
+		}
+	}
+	return nil
+}
+
+func (r *protoRenderer) message(m *descriptorpb.DescriptorProto, scope, indent string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%smessage %s ", indent, m.GetName())
+	b.WriteString(r.messageBody(m, scope+"."+m.GetName(), indent))
+	return b.String()
+}
+
+// messageBody renders `{ ... }` of m, whose full name is scope.
+func (r *protoRenderer) messageBody(m *descriptorpb.DescriptorProto, scope, indent string) string {
+	in := indent + "  "
+	var lines []string
+	for _, o := range renderOptions(m.Options) {
+		lines = append(lines, in+"option "+o+";")
+	}
+	done := map[int32]bool{}
+	for _, f := range m.Field {
+		if f.OneofIndex != nil && !f.GetProto3Optional() {
+			idx := f.GetOneofIndex()
+			if done[idx] {
+				continue
+			}
+			done[idx] = true
+			o := m.OneofDecl[idx]
+			var ob strings.Builder
+			fmt.Fprintf(&ob, "%soneof %s {\n", in, o.GetName())
+			for _, opt := range renderOptions(o.Options) {
+				ob.WriteString(in + "  option " + opt + ";\n")
+			}
+			for _, of := range m.Field {
+				if of.OneofIndex != nil && of.GetOneofIndex() == idx {
+					ob.WriteString(r.field(of, m.NestedType, scope, in+"  ", false) + "\n")
+				}
+			}
+			ob.WriteString(in + "}")
+			lines = append(lines, ob.String())
+			continue
+		}
+		lines = append(lines, r.field(f, m.NestedType, scope, in, true))
+	}
+	for _, n := range m.NestedType {
+		if n.GetOptions().GetMapEntry() || r.isGroupType(scope, n.GetName(), m.Field) || r.isGroupType(scope, n.GetName(), m.Extension) {
+			continue
+		}
+		lines = append(lines, r.message(n, scope, in))
+	}
+	for _, e := range m.EnumType {
+		lines = append(lines, r.enum(e, in))
+	}
+	if len(m.Extension) > 0 {
+		lines = append(lines, r.extends(m.Extension, scope, m.NestedType, in))
+	}
+	for _, er := range m.ExtensionRange {
+		s := fmt.Sprintf("%sextensions %s", in, rangeText(er.GetStart(), er.GetEnd()-1))
+		if opts := renderOptions(er.Options); len(opts) > 0 {
+			s += " [" + strings.Join(opts, ", ") + "]"
+		}
+		lines = append(lines, s+";")
+	}
+	var reserved []string
+	for _, rr := range m.ReservedRange {
+		reserved = append(reserved, rangeText(rr.GetStart(), rr.GetEnd()-1))
+	}
+	if len(reserved) > 0 {
+		lines = append(lines, in+"reserved "+strings.Join(reserved, ", ")+";")
+	}
+	if len(m.ReservedName) > 0 {
+		lines = append(lines, in+"reserved "+r.reservedNames(m.ReservedName)+";")
+	}
+	if len(lines) == 0 {
+		return "{\n" + indent + "}"
+	}
+	return "{\n" + strings.Join(lines, "\n") + "\n" + indent + "}"
+}
+
+func rangeText(lo, hi int32) string {
+	switch {
+	case hi >= 536870911:
+		return fmt.Sprintf("%d to max", lo)
+	case lo == hi:
+		return strconv.Itoa(int(lo))
+	}
+	return fmt.Sprintf("%d to %d", lo, hi)
+}
+
+// reservedNames renders reserved names: identifiers in editions, string literals before.
+func (r *protoRenderer) reservedNames(names []string) string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		if r.syntax == "editions" {
+			out[i] = n
+		} else {
+			out[i] = quoteProto(n)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// field renders one field; groups and map fields are rendered from their nested message.
+func (r *protoRenderer) field(f *descriptorpb.FieldDescriptorProto, nested []*descriptorpb.DescriptorProto, scope, indent string, labeled bool) string {
+	label := ""
+	if labeled {
+		switch f.GetLabel() {
+		case descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+			label = "repeated "
+		case descriptorpb.FieldDescriptorProto_LABEL_REQUIRED:
+			label = "required "
+		default:
+			if r.syntax == "proto2" || f.GetProto3Optional() {
+				label = "optional "
+			}
+		}
+	}
+	list := r.fieldOptions(f)
+	if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_GROUP && r.syntax == "editions" && f.GetOptions().GetFeatures().MessageEncoding == nil {
+		// editions 没有 group 语法，以 DELIMITED 编码的消息字段表示
+		list = append([]string{"features.message_encoding = DELIMITED"}, list...)
+	}
+	opts := ""
+	if len(list) > 0 {
+		opts = " [" + strings.Join(list, ", ") + "]"
+	}
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		if r.syntax == "editions" {
+			break
+		}
+		g := findMessage(nested, scope, f.GetTypeName())
+		if g == nil {
+			g = &descriptorpb.DescriptorProto{Name: proto.String(baseName(f.GetTypeName()))}
+		}
+		return fmt.Sprintf("%s%sgroup %s = %d%s %s", indent, label, g.GetName(), f.GetNumber(), opts, r.messageBody(g, scope+"."+g.GetName(), indent))
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		if e := findMessage(nested, scope, f.GetTypeName()); e != nil && e.GetOptions().GetMapEntry() && len(e.Field) == 2 {
+			return fmt.Sprintf("%smap<%s, %s> %s = %d%s;", indent, r.fieldType(e.Field[0]), r.fieldType(e.Field[1]), f.GetName(), f.GetNumber(), opts)
+		}
+	}
+	return fmt.Sprintf("%s%s%s %s = %d%s;", indent, label, r.fieldType(f), f.GetName(), f.GetNumber(), opts)
+}
+
+func (r *protoRenderer) fieldType(f *descriptorpb.FieldDescriptorProto) string {
+	if f.TypeName != nil {
+		return r.typeName(f.GetTypeName())
+	}
+	return strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+}
+
+// fieldOptions renders the default and json_name pseudo-options followed by the field options.
+func (r *protoRenderer) fieldOptions(f *descriptorpb.FieldDescriptorProto) []string {
+	var opts []string
+	if f.DefaultValue != nil {
+		opts = append(opts, "default = "+defaultLiteral(f))
+	}
+	if f.JsonName != nil && f.GetJsonName() != jsonCamel(f.GetName()) && f.Extendee == nil {
+		opts = append(opts, "json_name = "+quoteProto(f.GetJsonName()))
+	}
+	return append(opts, renderOptions(f.Options)...)
+}
+
+// defaultLiteral turns a stored default_value back into proto syntax.
+func defaultLiteral(f *descriptorpb.FieldDescriptorProto) string {
+	v := f.GetDefaultValue()
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return quoteProto(v)
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// extends renders extension fields grouped by consecutive extendee.
+func (r *protoRenderer) extends(exts []*descriptorpb.FieldDescriptorProto, scope string, nested []*descriptorpb.DescriptorProto, indent string) string {
+	var blocks []string
+	for i := 0; i < len(exts); {
+		j := i
+		var b strings.Builder
+		fmt.Fprintf(&b, "%sextend %s {\n", indent, r.typeName(exts[i].GetExtendee()))
+		for ; j < len(exts) && exts[j].GetExtendee() == exts[i].GetExtendee(); j++ {
+			b.WriteString(r.field(exts[j], nested, scope, indent+"  ", true) + "\n")
+		}
+		b.WriteString(indent + "}")
+		blocks = append(blocks, b.String())
+		i = j
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func (r *protoRenderer) enum(e *descriptorpb.EnumDescriptorProto, indent string) string {
+	in := indent + "  "
+	var lines []string
+	for _, o := range renderOptions(e.Options) {
+		lines = append(lines, in+"option "+o+";")
+	}
+	for _, v := range e.Value {
+		s := fmt.Sprintf("%s%s = %d", in, v.GetName(), v.GetNumber())
+		if opts := renderOptions(v.Options); len(opts) > 0 {
+			s += " [" + strings.Join(opts, ", ") + "]"
+		}
+		lines = append(lines, s+";")
+	}
+	var reserved []string
+	for _, rr := range e.ReservedRange {
+		reserved = append(reserved, rangeText(rr.GetStart(), rr.GetEnd()))
+	}
+	if len(reserved) > 0 {
+		lines = append(lines, in+"reserved "+strings.Join(reserved, ", ")+";")
+	}
+	if len(e.ReservedName) > 0 {
+		lines = append(lines, in+"reserved "+r.reservedNames(e.ReservedName)+";")
+	}
+	return fmt.Sprintf("%senum %s {\n%s\n%s}", indent, e.GetName(), strings.Join(lines, "\n"), indent)
+}
+
+func (r *protoRenderer) service(s *descriptorpb.ServiceDescriptorProto) string {
+	var lines []string
+	for _, o := range renderOptions(s.Options) {
+		lines = append(lines, "  option "+o+";")
+	}
+	for _, m := range s.Method {
+		in, out := r.typeName(m.GetInputType()), r.typeName(m.GetOutputType())
+		if m.GetClientStreaming() {
+			in = "stream " + in
+		}
+		if m.GetServerStreaming() {
+			out = "stream " + out
+		}
+		line := fmt.Sprintf("  rpc %s (%s) returns (%s)", m.GetName(), in, out)
+		if opts := renderOptions(m.Options); len(opts) > 0 {
+			line += " {\n"
+			for _, o := range opts {
+				line += "    option " + o + ";\n"
+			}
+			line += "  }"
+		} else {
+			line += ";"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("service %s {\n}", s.GetName())
+	}
+	return fmt.Sprintf("service %s {\n%s\n}", s.GetName(), strings.Join(lines, "\n"))
+}
+
+// renderOptions renders the set fields of an options message as `name = value` in field-number
+// order. Features are spelled as features.x; extensions as (full.name).
+func renderOptions(opts proto.Message) []string {
+	if opts == nil || !opts.ProtoReflect().IsValid() {
+		return nil
+	}
+	type entry struct {
+		fd protoreflect.FieldDescriptor
+		v  protoreflect.Value
+	}
+	var entries []entry
+	opts.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		entries = append(entries, entry{fd, v})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].fd.Number() < entries[j].fd.Number() })
+	var out []string
+	for _, e := range entries {
+		name := string(e.fd.Name())
+		if e.fd.IsExtension() {
+			name = "(" + string(e.fd.FullName()) + ")"
+		}
+		if name == "features" {
+			for _, sub := range renderOptions(e.v.Message().Interface()) {
+				out = append(out, "features."+sub)
+			}
+			continue
+		}
+		if e.fd.IsList() {
+			for i := 0; i < e.v.List().Len(); i++ {
+				out = append(out, name+" = "+optionLiteralOf(e.fd, e.v.List().Get(i)))
+			}
+			continue
+		}
+		out = append(out, name+" = "+optionLiteralOf(e.fd, e.v))
+	}
+	return out
+}
+
+// optionLiteralOf renders one option value in proto syntax.
+func optionLiteralOf(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.StringKind:
+		return quoteProto(v.String())
+	case protoreflect.BytesKind:
+		return `"` + cEscape(string(v.Bytes())) + `"`
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := v.Float()
+		switch {
+		case math.IsInf(f, 1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		case math.IsNaN(f):
+			return "nan"
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return aggregateLiteral(v.Message())
+	}
+	return v.String()
+}
+
+// aggregateLiteral renders a message option value in text format. prototext is not used because
+// its output whitespace is deliberately unstable.
+func aggregateLiteral(m protoreflect.Message) string {
+	type entry struct {
+		fd protoreflect.FieldDescriptor
+		v  protoreflect.Value
+	}
+	var entries []entry
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		entries = append(entries, entry{fd, v})
+		return true
+	})
+	if len(entries) == 0 {
+		return "{}"
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].fd.Number() < entries[j].fd.Number() })
+	var parts []string
+	for _, e := range entries {
+		name := string(e.fd.Name())
+		if e.fd.IsExtension() {
+			name = "[" + string(e.fd.FullName()) + "]"
+		} else if e.fd.Kind() == protoreflect.GroupKind {
+			name = string(e.fd.Message().Name())
+		}
+		switch {
+		case e.fd.IsList():
+			for i := 0; i < e.v.List().Len(); i++ {
+				parts = append(parts, name+": "+optionLiteralOf(e.fd, e.v.List().Get(i)))
+			}
+		case e.fd.IsMap():
+			// map 以 key/value 条目表示
+			var keys []protoreflect.MapKey
+			e.v.Map().Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				keys = append(keys, k)
+				return true
+			})
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			for _, k := range keys {
+				kv := optionLiteralOf(e.fd.MapKey(), k.Value())
+				vv := optionLiteralOf(e.fd.MapValue(), e.v.Map().Get(k))
+				parts = append(parts, name+": { key: "+kv+" value: "+vv+" }")
+			}
+		default:
+			parts = append(parts, name+": "+optionLiteralOf(e.fd, e.v))
+		}
+	}
+	return "{ " + strings.Join(parts, " ") + " }"
+}
+
+// quoteProto renders s as a double-quoted proto string literal.
+func quoteProto(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
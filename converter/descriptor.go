@@ -0,0 +1,275 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// 注册 import 可能引用的 well-known 文件
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// buildDescriptorSet compiles proto sources (import path -> text) into a FileDescriptorSet that
+// also holds their imports, dependencies first, like protoc --include_imports. Source code info
+// (declaration spans) is kept when sourceInfo is set.
+func buildDescriptorSet(sources map[string]string, sourceInfo bool) (*descriptorpb.FileDescriptorSet, *protoregistry.Files, error) {
+	files := map[string]*descriptorpb.FileDescriptorProto{}
+	var parsers []*descParser
+	for _, name := range sortedKeys(sources) {
+		p, err := parseDescriptor(name, sources[name])
+		if err != nil {
+			return nil, nil, err
+		}
+		if sourceInfo {
+			p.fd.SourceCodeInfo = &descriptorpb.SourceCodeInfo{Location: p.locs}
+		}
+		files[name] = p.fd
+		parsers = append(parsers, p)
+	}
+	// 不在输出中的 import 取自 well-known 文件
+	for queue := sortedKeys(files); len(queue) > 0; queue = queue[1:] {
+		for _, dep := range files[queue[0]].Dependency {
+			if _, ok := files[dep]; ok {
+				continue
+			}
+			fd, err := protoregistry.GlobalFiles.FindFileByPath(dep)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: 无法找到 import %q", queue[0], dep)
+			}
+			files[dep] = protodesc.ToFileDescriptorProto(fd)
+			queue = append(queue, dep)
+		}
+	}
+
+	symbols := map[string]byte{}
+	for _, fd := range files {
+		collectSymbols(fd, symbols)
+	}
+	for _, p := range parsers {
+		for _, r := range p.refs {
+			fqn, kind, ok := resolveSymbol(symbols, r.Scope, *r.Name)
+			if !ok || kind == 'p' {
+				return nil, nil, fmt.Errorf("%s: 无法解析类型 %s", r.Pos, *r.Name)
+			}
+			*r.Name = fqn
+			if r.Field != nil && r.Field.Type == nil {
+				if kind == 'e' {
+					r.Field.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+				} else {
+					r.Field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				}
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{File: orderFiles(files)}
+	reg, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, nil, fmt.Errorf("描述符校验失败: %w", err)
+	}
+	for _, p := range parsers {
+		for _, po := range p.pending {
+			if err := interpretOption(reg, po); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", po.Pos, err)
+			}
+		}
+	}
+	return set, reg, nil
+}
+
+// collectSymbols records the packages ('p'), messages ('m') and enums ('e') of fd by full name.
+func collectSymbols(fd *descriptorpb.FileDescriptorProto, out map[string]byte) {
+	pkg := ""
+	if fd.GetPackage() != "" {
+		for _, part := range strings.Split(fd.GetPackage(), ".") {
+			pkg += "." + part
+			if _, ok := out[pkg]; !ok {
+				out[pkg] = 'p'
+			}
+		}
+	}
+	var walk func(scope string, msgs []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto)
+	walk = func(scope string, msgs []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto) {
+		for _, e := range enums {
+			out[scope+"."+e.GetName()] = 'e'
+		}
+		for _, m := range msgs {
+			out[scope+"."+m.GetName()] = 'm'
+			walk(scope+"."+m.GetName(), m.NestedType, m.EnumType)
+		}
+	}
+	walk(pkg, fd.MessageType, fd.EnumType)
+}
+
+// resolveSymbol looks name up from scope outwards following protobuf scoping rules.
+func resolveSymbol(symbols map[string]byte, scope, name string) (string, byte, bool) {
+	if strings.HasPrefix(name, ".") {
+		k, ok := symbols[name]
+		return name, k, ok
+	}
+	first := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		first = name[:i]
+	}
+	for {
+		if k, ok := symbols[scope+"."+first]; ok {
+			if first == name {
+				return scope + "." + name, k, true
+			}
+			if k2, ok := symbols[scope+"."+name]; ok {
+				return scope + "." + name, k2, true
+			}
+			// 首段命中非聚合类型时不再向外查找
+			if k != 'p' && k != 'm' {
+				return "", 0, false
+			}
+		}
+		if scope == "" {
+			return "", 0, false
+		}
+		scope = scope[:strings.LastIndexByte(scope, '.')]
+	}
+}
+
+// orderFiles lists files with every dependency ahead of its dependents, otherwise by name.
+func orderFiles(files map[string]*descriptorpb.FileDescriptorProto) []*descriptorpb.FileDescriptorProto {
+	var out []*descriptorpb.FileDescriptorProto
+	done := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		fd, ok := files[name]
+		if !ok || done[name] {
+			return
+		}
+		done[name] = true
+		for _, dep := range fd.Dependency {
+			visit(dep)
+		}
+		out = append(out, fd)
+	}
+	for _, name := range sortedKeys(files) {
+		visit(name)
+	}
+	return out
+}
+
+// interpretOption resolves a custom option against the linked files and stores it in its
+// options message as an extension field, which is how protoc serializes interpreted options.
+func interpretOption(reg *protoregistry.Files, po pendingOption) error {
+	target := po.Opts.ProtoReflect().Descriptor()
+	var xd protoreflect.ExtensionDescriptor
+	for _, fqn := range optionScopes(strings.TrimPrefix(po.Scope, "."), po.Name[0].Name) {
+		if d, err := reg.FindDescriptorByName(protoreflect.FullName(fqn)); err == nil {
+			if x, ok := d.(protoreflect.ExtensionDescriptor); ok && x.ContainingMessage().FullName() == target.FullName() {
+				xd = x
+				break
+			}
+		}
+	}
+	if xd == nil {
+		return fmt.Errorf("未知的 option (%s)", po.Name[0].Name)
+	}
+	xt := dynamicpb.NewExtensionType(xd)
+	holder := dynamicpb.NewMessage(target)
+	fd := protoreflect.FieldDescriptor(xt.TypeDescriptor())
+	m := protoreflect.Message(holder)
+	for _, part := range po.Name[1:] {
+		if fd.Message() == nil || fd.IsList() {
+			return fmt.Errorf("option %s 不是消息类型", joinOptionName(po.Name))
+		}
+		m = m.Mutable(fd).Message()
+		if part.Ext {
+			return fmt.Errorf("option %s 暂不支持嵌套扩展", joinOptionName(po.Name))
+		}
+		if fd = m.Descriptor().Fields().ByName(protoreflect.Name(part.Name)); fd == nil {
+			return fmt.Errorf("未知的 option %s", joinOptionName(po.Name))
+		}
+	}
+	switch {
+	case po.Value.Kind == '{':
+		if fd.Message() == nil {
+			return fmt.Errorf("option %s 不是消息类型", joinOptionName(po.Name))
+		}
+		var sub protoreflect.Message
+		if fd.IsList() {
+			sub = m.Mutable(fd).List().NewElement().Message()
+		} else {
+			sub = m.Mutable(fd).Message()
+		}
+		if err := (prototext.UnmarshalOptions{Resolver: dynamicpb.NewTypes(reg)}).Unmarshal([]byte(po.Value.Text), sub.Interface()); err != nil {
+			return fmt.Errorf("option %s 的聚合值无效: %v", joinOptionName(po.Name), err)
+		}
+		if fd.IsList() {
+			m.Mutable(fd).List().Append(protoreflect.ValueOfMessage(sub))
+		}
+	default:
+		if err := setOptionField(m, fd, po.Value); err != nil {
+			return err
+		}
+	}
+	b, err := proto.Marshal(holder)
+	if err != nil {
+		return err
+	}
+	return proto.UnmarshalOptions{Merge: true}.Unmarshal(b, po.Opts)
+}
+
+// writeDescriptorSet builds the descriptor set of the output files and writes it in binary form
+// to t.DescriptorSet and, when t.DescriptorJSON is set, as protojson.
+func writeDescriptorSet(t exportTarget, sources map[string]string, dry bool) error {
+	if dry {
+		fmt.Printf("[dry] write descriptor set %s\n", t.DescriptorSet)
+		if t.DescriptorJSON != "" {
+			fmt.Printf("[dry] write descriptor set %s\n", t.DescriptorJSON)
+		}
+		return nil
+	}
+	set, reg, err := buildDescriptorSet(sources, t.DescriptorSourceInfo)
+	if err != nil {
+		return err
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return err
+	}
+	if err := writeFileMkdir(t.DescriptorSet, b); err != nil {
+		return err
+	}
+	if t.DescriptorJSON == "" {
+		return nil
+	}
+	// 以已链接的类型重新解析，使自定义 option 以扩展字段而非未知字段出现在 JSON 中
+	types := dynamicpb.NewTypes(reg)
+	full := &descriptorpb.FileDescriptorSet{}
+	if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(b, full); err != nil {
+		return err
+	}
+	js, err := protojson.MarshalOptions{Multiline: true, Indent: "  ", Resolver: types}.Marshal(full)
+	if err != nil {
+		return err
+	}
+	return writeFileMkdir(t.DescriptorJSON, append(js, '\n'))
+}
+
+func writeFileMkdir(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,246 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const demoProto = `syntax = "proto3";
+package demo;
+import "google/protobuf/timestamp.proto";
+option go_package = "example.com/demo";
+
+// a user
+message User {
+  int64 user_id = 1;
+  optional string nick_name = 2;
+  map<string, Role> roles = 3;
+  google.protobuf.Timestamp created = 4;
+  oneof contact {
+    string email = 5;
+    string phone = 6 [json_name = "tel"];
+  }
+  Address home = 7;
+  message Address { string city = 1; }
+  enum Role {
+    ROLE_UNSPECIFIED = 0;
+    ROLE_ADMIN = 1;
+  }
+  reserved 9, 10 to 12;
+  reserved "old";
+}
+
+service Users {
+  rpc Watch (stream User) returns (stream .demo.User);
+}
+`
+
+// demoDescriptor is the descriptor of demoProto, written by hand in the form protoc emits;
+// TestDescriptorSetMatchesProtoc checks against real protoc output.
+const demoDescriptor = `
+name: "demo.proto"
+package: "demo"
+dependency: "google/protobuf/timestamp.proto"
+message_type {
+  name: "User"
+  field { name: "user_id" number: 1 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "userId" }
+  field { name: "nick_name" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING oneof_index: 1 json_name: "nickName" proto3_optional: true }
+  field { name: "roles" number: 3 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".demo.User.RolesEntry" json_name: "roles" }
+  field { name: "created" number: 4 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "created" }
+  field { name: "email" number: 5 label: LABEL_OPTIONAL type: TYPE_STRING oneof_index: 0 json_name: "email" }
+  field { name: "phone" number: 6 label: LABEL_OPTIONAL type: TYPE_STRING oneof_index: 0 json_name: "tel" }
+  field { name: "home" number: 7 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".demo.User.Address" json_name: "home" }
+  nested_type {
+    name: "RolesEntry"
+    field { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "key" }
+    field { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".demo.User.Role" json_name: "value" }
+    options { map_entry: true }
+  }
+  nested_type {
+    name: "Address"
+    field { name: "city" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "city" }
+  }
+  enum_type {
+    name: "Role"
+    value { name: "ROLE_UNSPECIFIED" number: 0 }
+    value { name: "ROLE_ADMIN" number: 1 }
+  }
+  oneof_decl { name: "contact" }
+  oneof_decl { name: "_nick_name" }
+  reserved_range { start: 9 end: 10 }
+  reserved_range { start: 10 end: 13 }
+  reserved_name: "old"
+}
+service {
+  name: "Users"
+  method { name: "Watch" input_type: ".demo.User" output_type: ".demo.User" client_streaming: true server_streaming: true }
+}
+options { go_package: "example.com/demo" }
+syntax: "proto3"
+`
+
+// optionSources declares custom options and uses them in several forms.
+var optionSources = map[string]string{
+	"ext.proto": `syntax = "proto2";
+package ext;
+import "google/protobuf/descriptor.proto";
+message Rule { optional int32 gt = 1; }
+extend google.protobuf.FieldOptions {
+  optional Rule rule = 50001;
+  optional string label = 50002;
+}
+extend google.protobuf.MessageOptions { optional uint32 msg_id = 50003; }`,
+	"use.proto": `syntax = "proto3";
+package ext.use;
+import "ext.proto";
+message M {
+  option (msg_id) = 7;
+  int32 a = 1 [(ext.rule).gt = 3, (label) = "x"];
+  int32 b = 2 [(.ext.rule) = { gt: 4 }, deprecated = true];
+}`,
+}
+
+func TestBuildDescriptorSet(t *testing.T) {
+	set, _, err := buildDescriptorSet(map[string]string{"demo.proto": demoProto}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.File) != 2 || set.File[0].GetName() != "google/protobuf/timestamp.proto" {
+		t.Fatalf("files = %v, want timestamp.proto before demo.proto", fileNames(set))
+	}
+	want := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal([]byte(demoDescriptor), want); err != nil {
+		t.Fatal(err)
+	}
+	if got := set.File[1]; !proto.Equal(got, want) {
+		t.Errorf("descriptor mismatch\ngot:  %v\nwant: %v", prototext.Format(got), prototext.Format(want))
+	}
+
+	set, _, err = buildDescriptorSet(map[string]string{"demo.proto": demoProto}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, loc := range set.File[1].GetSourceCodeInfo().GetLocation() {
+		if proto.Equal(&descriptorpb.SourceCodeInfo_Location{Path: loc.Path}, &descriptorpb.SourceCodeInfo_Location{Path: []int32{4, 0, 2, 1}}) {
+			found = true
+			if want := []int32{8, 2, 32}; !equalInts(loc.Span, want) {
+				t.Errorf("span of nick_name = %v, want %v", loc.Span, want)
+			}
+		}
+	}
+	if !found {
+		t.Error("no source location for nick_name")
+	}
+}
+
+func TestBuildDescriptorSetOptions(t *testing.T) {
+	set, _, err := buildDescriptorSet(optionSources, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	use := set.File[len(set.File)-1]
+	if use.GetName() != "use.proto" {
+		t.Fatalf("files = %v", fileNames(set))
+	}
+	// 自定义 option 以扩展字段（未知字段）序列化
+	m := use.MessageType[0]
+	for _, opts := range []proto.Message{m.Options, m.Field[0].Options, m.Field[1].Options} {
+		if len(opts.ProtoReflect().GetUnknown()) == 0 {
+			t.Errorf("custom option not serialized in %v", opts)
+		}
+	}
+	if !m.Field[1].Options.GetDeprecated() {
+		t.Error("deprecated lost next to a custom option")
+	}
+
+	_, _, err = buildDescriptorSet(map[string]string{"bad.proto": `syntax = "proto3"; message A { Missing m = 1; }`}, false)
+	if err == nil || !strings.Contains(err.Error(), "无法解析类型 Missing") {
+		t.Errorf("unresolved type error = %v", err)
+	}
+}
+
+// TestDescriptorSetMatchesProtoc exports testdata/protoc (test.proto and its imports from the
+// protobuf-go test suite, with reserved ranges and names, groups, extensions and defaults)
+// unpruned and compares export.descriptorSet with the FileDescriptorSet protoc produced for the
+// same sources. Output files are flat, so only names and import paths may differ.
+func TestDescriptorSetMatchesProtoc(t *testing.T) {
+	fixture, err := filepath.Abs(filepath.Join("testdata", "protoc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &descriptorpb.FileDescriptorSet{}
+	if err := loadProto(filepath.Join(fixture, "descriptor_set.pb"), want); err != nil {
+		t.Fatal(err)
+	}
+	writeWorkspace(t, map[string]string{
+		"cfg.yaml": "import:\n  dir: " + filepath.ToSlash(fixture) + "\n  prune: false\n  keep:\n    files:\n      - file: internal/testprotos/test/test\n" +
+			"export:\n  language: go\n  dir: out\n  descriptorSet: out.pb\n",
+	})
+	if err := (&Exporter{ConfigPath: "cfg.yaml"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	got := &descriptorpb.FileDescriptorSet{}
+	if err := loadProto("out.pb", got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.File) != len(want.File) {
+		t.Fatalf("files = %v, want %v", fileNames(got), fileNames(want))
+	}
+	byName := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, f := range got.File {
+		byName[f.GetName()] = f
+	}
+	for _, w := range want.File {
+		g := byName[filepath.Base(w.GetName())]
+		if g == nil {
+			t.Errorf("%s not exported", w.GetName())
+			continue
+		}
+		g.Name = w.Name
+		for i, dep := range g.Dependency {
+			for _, full := range w.Dependency {
+				if filepath.Base(full) == dep {
+					g.Dependency[i] = full
+				}
+			}
+		}
+		if !proto.Equal(g, w) {
+			t.Errorf("%s differs from protoc\ngot:  %v\nwant: %v", w.GetName(), prototext.Format(g), prototext.Format(w))
+		}
+	}
+}
+
+func loadProto(path string, m proto.Message) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+func fileNames(set *descriptorpb.FileDescriptorSet) []string {
+	var out []string
+	for _, f := range set.File {
+		out = append(out, f.GetName())
+	}
+	return out
+}
+
+func equalInts(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
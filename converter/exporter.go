@@ -2,11 +2,16 @@ package converter
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 )
 
 // Exporter loads config, resolves dependencies, prunes, and writes proto outputs.
+//
+// Non-empty option fields override the config file (precedence: flag > Set > Env > config >
+// default) and apply to every export target. Prune and DryRun are only used when the config
+// leaves them unset, unless they are given through SetPrune and SetDryRun.
 type Exporter struct {
 	ConfigPath    string
 	ExportDir     string
@@ -17,73 +22,306 @@ type Exporter struct {
 	FieldNameCase string
 	Prune         bool
 	DryRun        bool
+	// Strict turns keep rules that match nothing into errors instead of warnings.
+	Strict bool
+	// Set holds extra "path=value" overrides applied to the config before decoding,
+	// e.g. "export.namespace=Game.Proto" or "exports[1].dir=out/go". An export.<key>
+	// override also replaces that key in every exports entry.
+	Set []string
+	// Env holds overrides read from the environment, in the form of Set, applied before Set.
+	Env []string
+
+	// prunePinned 与 dryRunPinned 表示 Prune/DryRun 来自命令行，优先于配置
+	prunePinned  bool
+	dryRunPinned bool
+}
+
+// SetPrune sets Prune and makes it override import.prune in the config.
+func (e *Exporter) SetPrune(v bool) {
+	e.Prune, e.prunePinned = v, true
+}
+
+// SetDryRun sets DryRun and makes it override dryRun in the config.
+func (e *Exporter) SetDryRun(v bool) {
+	e.DryRun, e.dryRunPinned = v, true
+}
+
+// exportTarget is one export destination with its own options and keep rules.
+type exportTarget struct {
+	Dir             string
+	Language        string
+	Namespace       string
+	NamespaceMap    map[string]string
+	DeriveNamespace bool
+	NamespacePrefix string
+	PackageRewrite  map[string]string
+	FlattenPackage  string
+	FileNameCase    string
+	FieldNameCase   string
+	TypeNameCase    string
+	EnumValueCase   string
+	StripEnumPrefix bool
+	Acronyms        []string
+	PreserveJSON    bool
+	StripOptions    []string
+	Rename          RenameRules
+	Grouping        string
+	BundleName      string
+	Facade          string
+	// DescriptorSet, DescriptorSourceInfo and DescriptorJSON control descriptor set output.
+	DescriptorSet        string
+	DescriptorSourceInfo bool
+	DescriptorJSON       string
+	JSONSchema           string
+	FileOptions          map[string]string
+	LangOptions          LangOptions
+	Keep                 keepRules
 }
 
 // Run executes export with the current Exporter settings.
 func (e *Exporter) Run() error {
-	cfg, seeds, seedKeep, typeFieldKeep, err := readProtoConfig(e.ConfigPath)
+	return e.run(runHooks{})
+}
+
+// runHooks lets subcommands observe or redirect an export run.
+type runHooks struct {
+	// target may change each resolved target before it is written, e.g. to export into a
+	// scratch directory; dry-run is then ignored.
+	target func(i int, t *exportTarget)
+	// sources receives the source files reachable from the seeds of any target.
+	sources func(paths []string)
+}
+
+// run executes export with the given hooks.
+func (e *Exporter) run(h runHooks) error {
+	cfg, err := readProtoConfig(e.ConfigPath, e.Env, e.Set)
 	if err != nil {
 		return err
 	}
-	if cfg.Export.Dir != "" {
-		e.ExportDir = filepath.FromSlash(cfg.Export.Dir)
-	} else if e.ExportDir == "" {
-		e.ExportDir = "."
+	importDir := filepath.FromSlash(cfg.Import.Dir)
+	if e.ImportDir != "" {
+		importDir = e.ImportDir
 	}
-	if cfg.Import.Dir != "" {
-		e.ImportDir = filepath.FromSlash(cfg.Import.Dir)
-	}
-	if cfg.Export.Namespace != "" {
-		e.Namespace = cfg.Export.Namespace
+	if cfg.Import.DescriptorSet != "" {
+		if importDir != "" {
+			return fmt.Errorf("import.dir 与 import.descriptorSet 只能二选一")
+		}
+		// 描述符集先还原为 .proto，之后与目录输入走同一条解析与裁剪流程
+		importDir, err = importFromDescriptorSet(filepath.FromSlash(cfg.Import.DescriptorSet))
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(importDir)
 	}
-	if cfg.Export.Language != "" {
-		e.Language = strings.ToLower(cfg.Export.Language)
+	prune := true
+	switch {
+	case e.prunePinned:
+		prune = e.Prune
+	case cfg.Import.Prune != nil:
+		prune = *cfg.Import.Prune
 	}
-	if cfg.Export.FileNameCase != "" {
-		e.FileNameCase = strings.ToLower(cfg.Export.FileNameCase)
-	} else if e.FileNameCase == "" {
-		e.FileNameCase = "keep"
+	dry := e.DryRun
+	if cfg.DryRun != nil && !e.dryRunPinned {
+		dry = *cfg.DryRun
 	}
-	if cfg.Export.FieldNameCase != "" {
-		e.FieldNameCase = strings.ToLower(cfg.Export.FieldNameCase)
-	} else if e.FieldNameCase == "" {
-		e.FieldNameCase = "keep"
+	if h.target != nil {
+		dry = false
 	}
-	switch e.Language {
-	case "csharp", "cs", "c#", "golang", "go", "lua":
-	case "":
-		return fmt.Errorf("配置缺失: language 必填。可选值: csharp/cs/c#、golang/go、lua")
-	default:
-		return fmt.Errorf("不支持的 language: %s (支持: csharp/cs/c#、golang/go、lua)", e.Language)
+
+	if e.ExportDir != "" && len(cfg.Exports) > 1 {
+		return fmt.Errorf("--export-dir 会让 %d 个导出目标写到同一目录，请改用 --set exports[i].dir=<目录>", len(cfg.Exports))
 	}
-	if cfg.Import.Prune != nil {
-		e.Prune = *cfg.Import.Prune
-	} else {
-		if !e.Prune {
-			e.Prune = true
+
+	var targets []exportTarget
+	for i, sec := range cfg.targets() {
+		t, err := e.target(sec)
+		if err == nil {
+			t.Keep, err = buildKeepRules(cfg.keepFor(sec))
+		}
+		if err != nil {
+			if len(cfg.Exports) > 0 {
+				return fmt.Errorf("exports[%d]: %w", i, err)
+			}
+			return err
+		}
+		if h.target != nil {
+			h.target(i, &t)
 		}
+		targets = append(targets, t)
 	}
-	if cfg.DryRun != nil {
-		e.DryRun = *cfg.DryRun
+
+	// 所有目标共享一次依赖解析与解析结果
+	var allSeeds []protoItem
+	for _, t := range targets {
+		allSeeds = append(allSeeds, t.Keep.Seeds...)
 	}
-	normalized, resolvedSeeds, err := (DepResolver{}).CollectWithImportsAndRoots(seeds, e.ImportDir)
+	normalized, resolvedSeeds, deps, err := (DepResolver{}).CollectWithImportsAndRoots(allSeeds, importDir)
 	if err != nil {
 		return err
 	}
-	if err := ensureDir(e.ExportDir, e.DryRun); err != nil {
+	parsed, err := (Pruner{}).ParseAll(normalized)
+	if err != nil {
 		return err
 	}
-	if !e.Prune {
-		seeds = normalized
-		seedKeep = nil
+	// 解析结果与 allSeeds 一一对应；按种子查找，各目标取回自己的种子
+	resolvedOf := map[protoItem]protoItem{}
+	for j, s := range allSeeds {
+		resolvedOf[s] = resolvedSeeds[j]
+	}
+
+	files := make([]map[string]*PFile, len(targets))
+	var issues []string
+	seenIssue := map[string]struct{}{}
+	for i := range targets {
+		t := &targets[i]
+		resolved := make([]protoItem, 0, len(t.Keep.Seeds))
+		for _, s := range t.Keep.Seeds {
+			resolved = append(resolved, resolvedOf[s])
+		}
+		reachable := closureOf(normalized, resolved, deps)
+		files[i] = map[string]*PFile{}
+		for _, it := range reachable {
+			files[i][shortPath(it.Path)] = parsed[shortPath(it.Path)]
+		}
+		if prune {
+			t.Keep.Seeds = resolved
+		} else {
+			t.Keep.Seeds = reachable
+			t.Keep.SeedKeep = nil
+		}
+		for _, is := range checkKeepRules(t.Keep.Types, parsed, resolved, t.Keep.SeedKeep, importDir) {
+			if _, ok := seenIssue[is.String()]; ok {
+				continue
+			}
+			seenIssue[is.String()] = struct{}{}
+			issues = append(issues, is.String())
+		}
+	}
+	if h.sources != nil {
+		reached := map[string]struct{}{}
+		for _, fs := range files {
+			for p := range fs {
+				reached[p] = struct{}{}
+			}
+		}
+		h.sources(sortedKeys(reached))
 	}
-	useSeeds := seeds
-	if e.Prune {
-		useSeeds = resolvedSeeds
+	if len(issues) > 0 {
+		if e.Strict {
+			return fmt.Errorf("keep 规则校验失败:\n  %s", strings.Join(issues, "\n  "))
+		}
+		for _, is := range issues {
+			fmt.Printf("警告: %s\n", is)
+		}
 	}
 
-	if _, _, err := (Pruner{}).BuildPrunedTempProtos(normalized, useSeeds, seedKeep, typeFieldKeep, e.ImportDir, e.ExportDir, e.Namespace, e.Language, e.FileNameCase, e.FieldNameCase, e.DryRun); err != nil {
-		return fmt.Errorf("写出转换后的 proto 失败: %w", err)
+	for i, t := range targets {
+		if sameLang(t.Language, "go") && t.LangOptions.GoModule == "" && len(t.LangOptions.GoPackageMap) == 0 && len(t.NamespaceMap) == 0 && !t.DeriveNamespace {
+			if pkgs := packagesOf(files[i]); len(pkgs) > 1 && t.Namespace != "" {
+				fmt.Printf("警告: %s: %d 个 proto package (%s) 共用 go_package %q，protoc-gen-go 会报冲突；请配置 languageOptions.goModule 或 goPackageMap\n", t.Dir, len(pkgs), strings.Join(pkgs, ", "), t.Namespace)
+			}
+		}
+		if err := ensureDir(t.Dir, dry); err != nil {
+			return err
+		}
+		if _, _, err := (Pruner{}).BuildPrunedTempProtos(files[i], t, importDir, dry); err != nil {
+			return fmt.Errorf("写出转换后的 proto 失败 (%s): %w", t.Dir, err)
+		}
 	}
 	return nil
 }
+
+// target resolves one export section: Exporter overrides win over the config, then defaults apply.
+func (e *Exporter) target(sec ExportSection) (exportTarget, error) {
+	t := exportTarget{
+		Dir:                  filepath.FromSlash(sec.Dir),
+		Language:             sec.Language,
+		Namespace:            sec.Namespace,
+		NamespaceMap:         sec.NamespaceMap,
+		DeriveNamespace:      sec.DeriveNamespace,
+		NamespacePrefix:      sec.NamespacePrefix,
+		PackageRewrite:       sec.PackageRewrite,
+		FlattenPackage:       sec.FlattenPackage,
+		FileNameCase:         sec.FileNameCase,
+		FieldNameCase:        sec.FieldNameCase,
+		TypeNameCase:         sec.TypeNameCase,
+		EnumValueCase:        sec.EnumValueCase,
+		StripEnumPrefix:      sec.StripEnumPrefix,
+		Acronyms:             sec.Acronyms,
+		PreserveJSON:         sec.PreserveJSONName,
+		StripOptions:         sec.StripOptions,
+		Rename:               sec.Rename,
+		Grouping:             sec.Grouping,
+		BundleName:           sec.BundleName,
+		Facade:               sec.Facade,
+		DescriptorSet:        filepath.FromSlash(sec.DescriptorSet),
+		DescriptorSourceInfo: sec.DescriptorSourceInfo,
+		DescriptorJSON:       filepath.FromSlash(sec.DescriptorJSON),
+		JSONSchema:           filepath.FromSlash(sec.JSONSchema),
+		FileOptions:          sec.FileOptions,
+		LangOptions:          sec.LanguageOptions,
+	}
+	if e.ExportDir != "" {
+		t.Dir = e.ExportDir
+	}
+	if e.Language != "" {
+		t.Language = e.Language
+	}
+	if e.Namespace != "" {
+		t.Namespace = e.Namespace
+	}
+	if e.FileNameCase != "" {
+		t.FileNameCase = e.FileNameCase
+	}
+	if e.FieldNameCase != "" {
+		t.FieldNameCase = e.FieldNameCase
+	}
+	if t.Dir == "" {
+		t.Dir = "."
+	}
+	t.Language = strings.ToLower(t.Language)
+	t.FileNameCase = strings.ToLower(t.FileNameCase)
+	if t.FileNameCase == "" {
+		t.FileNameCase = "keep"
+	}
+	for _, c := range []*string{&t.FieldNameCase, &t.TypeNameCase, &t.EnumValueCase} {
+		*c = strings.ToLower(*c)
+		if *c == "" {
+			*c = "keep"
+		}
+	}
+	if !enumAllowed("fileCase", t.FileNameCase) {
+		return t, fmt.Errorf("不支持的文件名风格: %s (支持: %s)", t.FileNameCase, strings.Join(enumSets["fileCase"], "/"))
+	}
+	// kebab/compact 得到的不是合法标识符，仅用于文件名
+	for _, c := range []string{t.FieldNameCase, t.TypeNameCase, t.EnumValueCase} {
+		if !enumAllowed("case", c) {
+			return t, fmt.Errorf("不支持的命名风格: %s (支持: %s)", c, strings.Join(enumSets["case"], "/"))
+		}
+	}
+	t.Grouping = strings.ToLower(strings.TrimSpace(t.Grouping))
+	if t.Grouping == "" {
+		t.Grouping = "source"
+	}
+	if !enumAllowed("grouping", t.Grouping) {
+		return t, fmt.Errorf("不支持的 grouping: %s (支持: %s)", t.Grouping, strings.Join(enumSets["grouping"], "/"))
+	}
+	if t.BundleName == "" {
+		t.BundleName = "bundle"
+	}
+	if t.Language == "" {
+		return t, fmt.Errorf("配置缺失: language 必填。可选值: %s", strings.Join(languageNames(), "/"))
+	}
+	if _, ok := lookupLang(t.Language); !ok {
+		return t, fmt.Errorf("不支持的 language: %s (支持: %s)", t.Language, strings.Join(languageNames(), "/"))
+	}
+	return t, nil
+}
+
+func packagesOf(files map[string]*PFile) []string {
+	set := map[string]struct{}{}
+	for _, pf := range files {
+		set[pf.Package] = struct{}{}
+	}
+	return sortedKeys(set)
+}
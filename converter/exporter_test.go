@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRunMultipleTargets(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/common.proto": "syntax = \"proto3\";\npackage game;\nmessage Id { int64 v = 1; }\n",
+		"proto/a.proto":      "syntax = \"proto3\";\npackage game;\nimport \"common.proto\";\nmessage A { Id id = 1; }\n",
+		"proto/b.proto":      "syntax = \"proto3\";\npackage game;\nmessage B { int32 n = 1; }\n",
+		"proto/c.proto":      "syntax = \"proto3\";\npackage game;\nmessage C { int32 n = 1; }\n",
+		"cfg.yaml": `import:
+  dir: proto
+export:
+  language: csharp
+exports:
+  - dir: out/ab
+    keep:
+      files:
+        - file: a
+        - file: b
+  - dir: out/c
+    keep:
+      files:
+        - file: c
+  - dir: out/ba
+    keep:
+      files:
+        - file: b
+        - file: a
+`,
+	})
+	if err := (&Exporter{ConfigPath: "cfg.yaml"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"ab": {"a.proto", "b.proto", "common.proto"},
+		"c":  {"c.proto"},
+		"ba": {"a.proto", "b.proto", "common.proto"},
+	}
+	for dir, files := range want {
+		entries, err := os.ReadDir(filepath.Join("out", dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Name())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, files) {
+			t.Errorf("out/%s = %v, want %v", dir, got, files)
+		}
+	}
+	// 同一个 --export-dir 会让各目标互相覆盖
+	if err := (&Exporter{ConfigPath: "cfg.yaml", ExportDir: "out/all"}).Run(); err == nil {
+		t.Error("Run accepted ExportDir with several exports")
+	}
+}
+
+func TestRunDryRunOverride(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/a.proto": "syntax = \"proto3\";\npackage game;\nmessage A { int32 n = 1; }\n",
+		"cfg.yaml":      "dryRun: true\nimport:\n  dir: proto\n  keep:\n    files:\n      - file: a\nexport:\n  dir: out\n  language: csharp\n",
+	})
+	// 直接赋值的 DryRun 只是默认值，配置优先；SetDryRun 优先于配置
+	if err := (&Exporter{ConfigPath: "cfg.yaml"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("out/a.proto"); err == nil {
+		t.Fatal("dryRun: true in the config still wrote out/a.proto")
+	}
+	exp := &Exporter{ConfigPath: "cfg.yaml"}
+	exp.SetDryRun(false)
+	if err := exp.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("out/a.proto"); err != nil {
+		t.Errorf("SetDryRun(false) did not override the config: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// writeJSONSchemas writes one JSON Schema per message of the output files into t.JSONSchema,
+// named <full name>.schema.json. Schemas follow the proto3 JSON mapping and reference each other
+// by file name.
+func writeJSONSchemas(t exportTarget, sources map[string]string, dry bool) error {
+	if dry {
+		fmt.Printf("[dry] write json schemas %s\n", t.JSONSchema)
+		return nil
+	}
+	_, reg, err := buildDescriptorSet(sources, false)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(sources) {
+		fd, err := reg.FindFileByPath(name)
+		if err != nil {
+			return err
+		}
+		var walk func(msgs protoreflect.MessageDescriptors) error
+		walk = func(msgs protoreflect.MessageDescriptors) error {
+			for i := 0; i < msgs.Len(); i++ {
+				md := msgs.Get(i)
+				if md.IsMapEntry() {
+					continue
+				}
+				b, err := json.MarshalIndent(messageSchema(md), "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := writeFileMkdir(filepath.Join(t.JSONSchema, schemaFileName(md)), append(b, '\n')); err != nil {
+					return err
+				}
+				if err := walk(md.Messages()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(fd.Messages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func schemaFileName(md protoreflect.MessageDescriptor) string {
+	return string(md.FullName()) + ".schema.json"
+}
+
+// messageSchema is the document for md: an object keyed by JSON field names. Parsers also accept
+// the original field names, so a field whose name differs from its JSON name is listed under both
+// and may appear under at most one of them. Each oneof allows at most one of its members.
+func messageSchema(md protoreflect.MessageDescriptor) map[string]any {
+	props := map[string]any{}
+	var required []string
+	var conds []any
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		fs := fieldSchema(f)
+		if opts, ok := f.Options().(*descriptorpb.FieldOptions); ok && opts.GetDeprecated() {
+			fs["deprecated"] = true
+		}
+		props[f.JSONName()] = fs
+		if name := string(f.Name()); name != f.JSONName() {
+			props[name] = fs
+			conds = append(conds, map[string]any{"not": map[string]any{"required": []string{f.JSONName(), name}}})
+		}
+		if f.Cardinality() == protoreflect.Required {
+			if string(f.Name()) == f.JSONName() {
+				required = append(required, f.JSONName())
+			} else {
+				conds = append(conds, present(f))
+			}
+		}
+	}
+	s := map[string]any{
+		"$schema":              jsonSchemaDraft,
+		"$id":                  schemaFileName(md),
+		"title":                string(md.FullName()),
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	var oneofs []any
+	for i := 0; i < md.Oneofs().Len(); i++ {
+		od := md.Oneofs().Get(i)
+		if od.IsSynthetic() {
+			continue
+		}
+		// 每个成员单独出现，或一个都不出现
+		var each, some []any
+		for j := 0; j < od.Fields().Len(); j++ {
+			req := present(od.Fields().Get(j))
+			each = append(each, req)
+			some = append(some, req)
+		}
+		each = append(each, map[string]any{"not": map[string]any{"anyOf": some}})
+		oneofs = append(oneofs, map[string]any{"oneOf": each})
+	}
+	switch all := append(oneofs, conds...); {
+	case len(all) == 1 && len(oneofs) == 1:
+		s["oneOf"] = oneofs[0].(map[string]any)["oneOf"]
+	case len(all) > 0:
+		s["allOf"] = all
+	}
+	return s
+}
+
+// present matches objects that set f under its JSON name or its original name.
+func present(f protoreflect.FieldDescriptor) map[string]any {
+	if name := string(f.Name()); name != f.JSONName() {
+		return map[string]any{"anyOf": []any{
+			map[string]any{"required": []string{f.JSONName()}},
+			map[string]any{"required": []string{name}},
+		}}
+	}
+	return map[string]any{"required": []string{f.JSONName()}}
+}
+
+// fieldSchema maps a field, including its repeated or map form.
+func fieldSchema(f protoreflect.FieldDescriptor) map[string]any {
+	switch {
+	case f.IsMap():
+		s := map[string]any{"type": "object", "additionalProperties": valueSchema(f.MapValue())}
+		switch f.MapKey().Kind() {
+		case protoreflect.BoolKind:
+			s["propertyNames"] = map[string]any{"enum": []string{"true", "false"}}
+		case protoreflect.StringKind:
+		default:
+			s["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		}
+		return s
+	case f.IsList():
+		return map[string]any{"type": "array", "items": valueSchema(f)}
+	}
+	return valueSchema(f)
+}
+
+// valueSchema maps a single value of f's type following the proto3 JSON mapping.
+func valueSchema(f protoreflect.FieldDescriptor) map[string]any {
+	switch f.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": math.MaxUint32}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// 64 位整数在 JSON 中以字符串表示
+		return map[string]any{"type": "string", "pattern": "^-?[0-9]+$"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "pattern": "^[0-9]+$"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "number"},
+			map[string]any{"enum": []string{"NaN", "Infinity", "-Infinity"}},
+		}}
+	case protoreflect.EnumKind:
+		ed := f.Enum()
+		if ed.FullName() == "google.protobuf.NullValue" {
+			return map[string]any{"type": "null"}
+		}
+		var names []string
+		for i := 0; i < ed.Values().Len(); i++ {
+			names = append(names, string(ed.Values().Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	}
+	md := f.Message()
+	if s := wellKnownSchema(md); s != nil {
+		return s
+	}
+	return map[string]any{"$ref": schemaFileName(md)}
+}
+
+// wellKnownSchema maps the google.protobuf types that have a special JSON form, or returns nil.
+func wellKnownSchema(md protoreflect.MessageDescriptor) map[string]any {
+	name := string(md.FullName())
+	if !strings.HasPrefix(name, "google.protobuf.") {
+		return nil
+	}
+	switch strings.TrimPrefix(name, "google.protobuf.") {
+	case "Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "Duration":
+		return map[string]any{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]{1,9})?s$`}
+	case "FieldMask":
+		return map[string]any{"type": "string"}
+	case "Struct":
+		return map[string]any{"type": "object"}
+	case "ListValue":
+		return map[string]any{"type": "array"}
+	case "Value":
+		return map[string]any{}
+	case "Empty":
+		return map[string]any{"type": "object", "additionalProperties": false}
+	case "Any":
+		return map[string]any{"type": "object", "properties": map[string]any{"@type": map[string]any{"type": "string"}}, "required": []string{"@type"}}
+	case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value", "Int32Value", "UInt32Value", "BoolValue", "StringValue", "BytesValue":
+		// 包装类型以其 value 字段的形式出现
+		return valueSchema(md.Fields().ByName("value"))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestWriteJSONSchemas(t *testing.T) {
+	sources := map[string]string{"demo.proto": demoProto, "num.proto": `syntax = "proto2";
+package demo;
+import "google/protobuf/wrappers.proto";
+message Num {
+  required int64 big = 1;
+  optional uint32 small = 2 [deprecated = true];
+  map<int32, double> ratio = 3;
+  optional google.protobuf.Int64Value wrapped = 4;
+  required int32 min_level = 5;
+}`}
+	dir := t.TempDir()
+	if err := writeJSONSchemas(exportTarget{JSONSchema: dir}, sources, false); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// map entry 不单独生成
+	if want := []string{"demo.Num.schema.json", "demo.User.Address.schema.json", "demo.User.schema.json"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+
+	read := func(name string) map[string]any {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	tests := []struct {
+		file string
+		path []string
+		want string
+	}{
+		{"demo.User.schema.json", []string{"$schema"}, `"https://json-schema.org/draft/2020-12/schema"`},
+		{"demo.User.schema.json", []string{"properties", "userId"}, `{"pattern":"^-?[0-9]+$","type":"string"}`},
+		{"demo.User.schema.json", []string{"properties", "tel"}, `{"type":"string"}`},
+		{"demo.User.schema.json", []string{"properties", "roles"}, `{"additionalProperties":{"enum":["ROLE_UNSPECIFIED","ROLE_ADMIN"],"type":"string"},"type":"object"}`},
+		{"demo.User.schema.json", []string{"properties", "created"}, `{"format":"date-time","type":"string"}`},
+		{"demo.User.schema.json", []string{"properties", "home"}, `{"$ref":"demo.User.Address.schema.json"}`},
+		// 原字段名同样可用，但不能与 JSON 名同时出现
+		{"demo.User.schema.json", []string{"properties", "user_id"}, `{"pattern":"^-?[0-9]+$","type":"string"}`},
+		{"demo.User.schema.json", []string{"properties", "phone"}, `{"type":"string"}`},
+		{"demo.User.schema.json", []string{"allOf", "0", "oneOf"}, `[{"required":["email"]},{"anyOf":[{"required":["tel"]},{"required":["phone"]}]},{"not":{"anyOf":[{"required":["email"]},{"anyOf":[{"required":["tel"]},{"required":["phone"]}]}]}}]`},
+		{"demo.User.schema.json", []string{"allOf", "1"}, `{"not":{"required":["userId","user_id"]}}`},
+		{"demo.Num.schema.json", []string{"required"}, `["big"]`},
+		{"demo.Num.schema.json", []string{"allOf"}, `[{"not":{"required":["minLevel","min_level"]}},{"anyOf":[{"required":["minLevel"]},{"required":["min_level"]}]}]`},
+		{"demo.Num.schema.json", []string{"properties", "small"}, `{"deprecated":true,"maximum":4294967295,"minimum":0,"type":"integer"}`},
+		{"demo.Num.schema.json", []string{"properties", "ratio", "propertyNames"}, `{"pattern":"^-?[0-9]+$"}`},
+		{"demo.Num.schema.json", []string{"properties", "wrapped"}, `{"pattern":"^-?[0-9]+$","type":"string"}`},
+	}
+	for _, tt := range tests {
+		var v any = read(tt.file)
+		for _, k := range tt.path {
+			if a, ok := v.([]any); ok {
+				i, _ := strconv.Atoi(k)
+				v = a[i]
+				continue
+			}
+			v = v.(map[string]any)[k]
+		}
+		got, _ := json.Marshal(v)
+		if string(got) != tt.want {
+			t.Errorf("%s %v = %s, want %s", tt.file, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// KeepIssue describes a keep rule in the config that does not match the parsed protos.
+type KeepIssue struct {
+	Rule    string
+	Message string
+}
+
+func (i KeepIssue) String() string { return i.Rule + ": " + i.Message }
+
+// checkKeepRules validates import.keep against the parsed definitions.
+func checkKeepRules(types []TypeRule, parsed map[string]*PFile, seeds []protoItem, seedKeep map[string]map[string]struct{}, inDir string) []KeepIssue {
+	var issues []KeepIssue
+
+	// 文件级：keep 中的名称必须是种子文件的顶层定义
+	seedFiles := map[string]*PFile{}
+	for _, s := range seeds {
+		p := shortPath(s.Path)
+		if pf := parsed[p]; pf != nil {
+			seedFiles[seedKeepPath(inDir, p)] = pf
+		}
+	}
+	for _, key := range sortedKeys(seedKeep) {
+		rule := "import.keep.files[" + key + "]"
+		pf := seedFiles[key]
+		if pf == nil {
+			issues = append(issues, KeepIssue{Rule: rule, Message: "未匹配到任何种子文件，keep 列表不会生效"})
+			continue
+		}
+		var names []string
+		for _, d := range pf.Defs {
+			if d.Kind != "extend" {
+				names = append(names, d.Name)
+			}
+		}
+		for _, n := range sortedKeys(seedKeep[key]) {
+			if !containsString(names, n) {
+				issues = append(issues, KeepIssue{Rule: rule, Message: fmt.Sprintf("未找到顶层定义 %q%s", n, suggestHint(n, names))})
+			}
+		}
+	}
+
+	// 类型级：type 必须指向 message，keep 中的字段必须存在
+	var allTypes []string
+	for _, pf := range parsed {
+		for _, d := range pf.Defs {
+			if d.Kind == "extend" {
+				continue
+			}
+			allTypes = append(allTypes, d.Name)
+			if pf.Package != "" {
+				allTypes = append(allTypes, pf.Package+"."+d.Name)
+			}
+		}
+	}
+	for _, tr := range types {
+		tname := strings.TrimSpace(tr.Type)
+		if tname == "" {
+			continue
+		}
+		rule := "import.keep.types[" + tname + "]"
+		var msgs []TopDef
+		var enums int
+		for _, pf := range parsed {
+			for _, d := range pf.Defs {
+				if d.Name != tname && (pf.Package == "" || pf.Package+"."+d.Name != tname) {
+					continue
+				}
+				if d.Kind == "message" {
+					msgs = append(msgs, d)
+				} else {
+					enums++
+				}
+			}
+		}
+		if len(msgs) == 0 {
+			if enums > 0 {
+				issues = append(issues, KeepIssue{Rule: rule, Message: fmt.Sprintf("%q 是 enum，字段裁剪仅作用于 message", tname)})
+			} else {
+				issues = append(issues, KeepIssue{Rule: rule, Message: fmt.Sprintf("未找到类型 %q%s", tname, suggestHint(tname, allTypes))})
+			}
+			continue
+		}
+		var fields []string
+		for _, d := range msgs {
+			fields = append(fields, messageFieldNames(d.Text)...)
+		}
+		for _, f := range tr.Keep {
+			f = strings.TrimSpace(f)
+			if f != "" && !containsString(fields, f) {
+				issues = append(issues, KeepIssue{Rule: rule, Message: fmt.Sprintf("message 中没有字段 %q%s", f, suggestHint(f, fields))})
+			}
+		}
+	}
+	return issues
+}
+
+var reFieldName = regexp.MustCompile(`(?m)^\s*(?:repeated\s+|optional\s+|required\s+)?(?:map\s*<[^>]+>|[A-Za-z_][\w\.]*)\s+([A-Za-z_]\w*)\s*=\s*\d+`)
+
+// messageFieldNames lists the direct fields of a message, including oneof members.
+func messageFieldNames(def string) []string {
+	i := strings.Index(def, "{")
+	j := strings.LastIndex(def, "}")
+	if i < 0 || j <= i {
+		return nil
+	}
+	body := stripComments(def[i+1 : j])
+	// 去掉嵌套的 message/enum/extend 与 group 的消息体，仅保留本层与 oneof 内的字段
+	var b strings.Builder
+	var groups []string
+	for cur := 0; cur < len(body); {
+		if isIdentStart(body[cur]) && (cur == 0 || !isIdent(body[cur-1])) {
+			kw, next := readKeyword(body, cur)
+			if kw == "group" {
+				name, k := readIdentAfter(body, next)
+				for k < len(body) && body[k] != '{' && body[k] != ';' {
+					k++
+				}
+				if _, end := findBlock(body, k); name != "" && end > k {
+					groups = append(groups, strings.ToLower(name))
+					b.WriteString(";")
+					cur = end
+					continue
+				}
+			}
+			if kw == "message" || kw == "enum" || kw == "extend" {
+				k := next
+				for k < len(body) && body[k] != '{' && body[k] != ';' {
+					k++
+				}
+				if k < len(body) && body[k] == '{' {
+					if _, end := findBlock(body, k); end > k {
+						cur = end
+						continue
+					}
+				}
+			}
+			b.WriteString(body[cur:next])
+			cur = next
+			continue
+		}
+		b.WriteByte(body[cur])
+		cur++
+	}
+	var out []string
+	for _, m := range reFieldName.FindAllStringSubmatch(strings.NewReplacer("{", "\n", "}", "\n", ";", ";\n").Replace(b.String()), -1) {
+		out = append(out, m[1])
+	}
+	return append(out, groups...)
+}
+
+// suggestHint formats close matches of name among candidates, e.g. "（是否为 Account?）".
+func suggestHint(name string, candidates []string) string {
+	s := suggest(name, candidates)
+	if len(s) == 0 {
+		return ""
+	}
+	return "（是否为 " + strings.Join(s, " / ") + "?）"
+}
+
+func suggest(name string, candidates []string) []string {
+	type cand struct {
+		name string
+		dist int
+	}
+	limit := len(name) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	seen := map[string]struct{}{}
+	var cs []cand
+	for _, c := range candidates {
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d <= limit {
+			cs = append(cs, cand{c, d})
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].dist != cs[j].dist {
+			return cs[i].dist < cs[j].dist
+		}
+		return cs[i].name < cs[j].name
+	})
+	var out []string
+	for i := 0; i < len(cs) && i < 3; i++ {
+		out = append(out, cs[i].name)
+	}
+	return out
+}
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessageFieldNames(t *testing.T) {
+	def := `message Account {
+  // comment = 9;
+  shared.Identifier id = 1;
+  repeated string names = 2;
+  map<string, shared.Pair> pairs = 3 [deprecated = true];
+  message Inner { int32 hidden = 1; }
+  oneof choice {
+    int32 a = 6;
+    string b = 7;
+  }
+  reserved 8;
+  repeated group Result = 9 {
+    required string url = 10;
+  }
+}`
+	want := []string{"id", "names", "pairs", "a", "b", "result"}
+	if got := messageFieldNames(def); !reflect.DeepEqual(got, want) {
+		t.Errorf("messageFieldNames() = %v, want %v", got, want)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name  string
+		cands []string
+		out   []string
+	}{
+		{"Identifer", []string{"Identifier", "Pair", "ErrorCode"}, []string{"Identifier"}},
+		{"acount", []string{"Account", "Amount", "Player"}, []string{"Account", "Amount"}},
+		{"Foo", []string{"Identifier", "Pair"}, nil},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.name, tt.cands); !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("suggest(%q) = %v, want %v", tt.name, got, tt.out)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package converter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// LangOptions configures language-specific file options; empty values are derived
+// from the namespace or the proto package.
+type LangOptions struct {
+	JavaPackage        string `yaml:"javaPackage"`
+	JavaMultipleFiles  *bool  `yaml:"javaMultipleFiles"`
+	JavaOuterClassname string `yaml:"javaOuterClassname"`
+	ObjcClassPrefix    string `yaml:"objcClassPrefix"`
+	PhpNamespace       string `yaml:"phpNamespace"`
+	RubyPackage        string `yaml:"rubyPackage"`
+	SwiftPrefix        string `yaml:"swiftPrefix"`
+	// GoModule is the Go import path prefix; go_package becomes <goModule>/<package path>;<name>.
+	GoModule string `yaml:"goModule"`
+	// GoPackageMap maps a proto package or source file (like protoc's M flags) to a Go import path,
+	// optionally with an explicit package name as "import/path;name".
+	GoPackageMap map[string]string `yaml:"goPackageMap"`
+}
+
+// fileOption is one `option name = value;` line of an output file; Value is already proto syntax.
+// Derived marks generated values that no config setting asked for; source options override them.
+type fileOption struct {
+	Name    string
+	Value   string
+	Derived bool
+}
+
+// langFile carries what the option generators know about one output file.
+type langFile struct {
+	Package    string // 输出文件的 proto package
+	Namespace  string // export.namespace（可为空）
+	FileName   string // 输出文件名（不含扩展名）
+	SourcePath string // 源文件相对 import.dir 的路径
+}
+
+type langSpec struct {
+	Names   []string
+	Options func(f langFile, o LangOptions) []fileOption
+	// Carry lists the source file options passed through for this language, besides commonCarry.
+	Carry []string
+	// Derive builds the namespace of a proto package under prefix; nil uses Pascal segments
+	// joined by dots, e.g. ("Acme", "game.shared") -> Acme.Game.Shared.
+	Derive func(prefix, pkg string) string
+}
+
+var langTable = []langSpec{
+	{Names: []string{"csharp", "cs", "c#"}, Options: func(f langFile, _ LangOptions) []fileOption {
+		if f.Namespace == "" {
+			return nil
+		}
+		return []fileOption{strOption("csharp_namespace", f.Namespace)}
+	}, Carry: []string{"csharp_namespace"}},
+	{Names: []string{"golang", "go"}, Options: func(f langFile, o LangOptions) []fileOption {
+		if gp := goPackage(f, o); gp != "" {
+			return []fileOption{strOption("go_package", gp)}
+		}
+		return nil
+	}, Derive: func(prefix, pkg string) string {
+		return withGoName(strings.Trim(prefix+"/"+strings.ReplaceAll(pkg, ".", "/"), "/"))
+	}, Carry: []string{"go_package"}},
+	{Names: []string{"java", "kotlin", "kt"}, Options: javaOptions, Derive: func(prefix, pkg string) string {
+		return strings.ToLower(strings.Trim(prefix+"."+pkg, "."))
+	}, Carry: []string{"java_package", "java_outer_classname", "java_multiple_files", "java_string_check_utf8", "java_generic_services"}},
+	{Names: []string{"objc", "objectivec", "objective-c"}, Options: func(f langFile, o LangOptions) []fileOption {
+		// 配置的命名空间原样作为类名前缀，仅由 package 推导时取首字母
+		prefix := firstNonEmpty(o.ObjcClassPrefix, f.Namespace)
+		if prefix == "" && f.Package != "" {
+			return []fileOption{derived(strOption("objc_class_prefix", objcPrefix(f.Package)))}
+		}
+		if prefix == "" {
+			return nil
+		}
+		return []fileOption{strOption("objc_class_prefix", prefix)}
+	}, Derive: func(prefix, pkg string) string {
+		return prefix + objcPrefix(pkg)
+	}, Carry: []string{"objc_class_prefix"}},
+	{Names: []string{"php"}, Options: func(f langFile, o LangOptions) []fileOption {
+		ns := firstNonEmpty(o.PhpNamespace, joinPackage(f.Namespace, "\\", false))
+		if ns == "" && f.Package != "" {
+			return []fileOption{derived(strOption("php_namespace", joinPackage(f.Package, "\\", true)))}
+		}
+		if ns == "" {
+			return nil
+		}
+		return []fileOption{strOption("php_namespace", ns)}
+	}, Carry: []string{"php_namespace", "php_metadata_namespace", "php_class_prefix"}},
+	{Names: []string{"ruby", "rb"}, Options: func(f langFile, o LangOptions) []fileOption {
+		pkg := firstNonEmpty(o.RubyPackage, joinPackage(f.Namespace, "::", false))
+		if pkg == "" && f.Package != "" {
+			return []fileOption{derived(strOption("ruby_package", joinPackage(f.Package, "::", true)))}
+		}
+		if pkg == "" {
+			return nil
+		}
+		return []fileOption{strOption("ruby_package", pkg)}
+	}, Carry: []string{"ruby_package"}},
+	{Names: []string{"swift"}, Options: func(f langFile, o LangOptions) []fileOption {
+		prefix := firstNonEmpty(o.SwiftPrefix, f.Namespace)
+		if prefix == "" && f.Package != "" {
+			return []fileOption{derived(strOption("swift_prefix", swiftPrefix(f.Package)))}
+		}
+		if prefix == "" {
+			return nil
+		}
+		return []fileOption{strOption("swift_prefix", prefix)}
+	}, Derive: func(prefix, pkg string) string {
+		return joinPackage(strings.Trim(prefix+"."+pkg, "."), "_", true) + "_"
+	}, Carry: []string{"swift_prefix"}},
+	{Names: []string{"python", "py"}, Options: func(langFile, LangOptions) []fileOption { return nil }, Carry: []string{"py_generic_services"}},
+	{Names: []string{"lua"}, Options: func(langFile, LangOptions) []fileOption { return nil }},
+}
+
+// commonCarry lists the source file options passed through for every language.
+var commonCarry = []string{"optimize_for", "cc_enable_arenas", "deprecated"}
+
+// fileOptionFields are the fields of google.protobuf.FileOptions, used to tell string options from
+// enum, boolean and numeric ones.
+var fileOptionFields = (&descriptorpb.FileOptions{}).ProtoReflect().Descriptor().Fields()
+
+func javaOptions(f langFile, o LangOptions) []fileOption {
+	var out []fileOption
+	if pkg := firstNonEmpty(o.JavaPackage, f.Namespace); pkg != "" {
+		out = append(out, strOption("java_package", pkg))
+	} else if f.Package != "" {
+		out = append(out, derived(strOption("java_package", f.Package)))
+	}
+	if o.JavaMultipleFiles != nil {
+		out = append(out, fileOption{Name: "java_multiple_files", Value: strconv.FormatBool(*o.JavaMultipleFiles)})
+	}
+	outer := strOption("java_outer_classname", strings.ReplaceAll(firstNonEmpty(o.JavaOuterClassname, "{file}Proto"), "{file}", toCamel(removeNonIdent(f.FileName))))
+	if o.JavaOuterClassname == "" {
+		outer = derived(outer)
+	}
+	return append(out, outer)
+}
+
+// goPackage computes go_package for one file: goPackageMap (by source file, then package),
+// then goModule plus the package path, then the plain namespace.
+func goPackage(f langFile, o LangOptions) string {
+	for _, key := range []string{f.SourcePath, f.Package} {
+		if v, ok := o.GoPackageMap[key]; ok && key != "" {
+			return withGoName(v)
+		}
+	}
+	if o.GoModule != "" {
+		mod := strings.TrimSuffix(o.GoModule, "/")
+		if f.Package == "" {
+			return withGoName(mod)
+		}
+		return withGoName(mod + "/" + strings.ReplaceAll(f.Package, ".", "/"))
+	}
+	return f.Namespace
+}
+
+// withGoName appends ";<name>" derived from the last path element unless already present.
+func withGoName(importPath string) string {
+	if strings.Contains(importPath, ";") {
+		return importPath
+	}
+	name := importPath[strings.LastIndex(importPath, "/")+1:]
+	name = strings.ToLower(removeNonIdent(name))
+	if name == "" || isDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return importPath + ";" + name
+}
+
+// lookupLang returns the spec for a language name or alias (case-insensitive).
+func lookupLang(name string) (langSpec, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, l := range langTable {
+		for _, n := range l.Names {
+			if n == name {
+				return l, true
+			}
+		}
+	}
+	return langSpec{}, false
+}
+
+// sameLang reports whether two language names are aliases of the same table entry.
+func sameLang(a, b string) bool {
+	la, ok1 := lookupLang(a)
+	lb, ok2 := lookupLang(b)
+	return ok1 && ok2 && la.Names[0] == lb.Names[0]
+}
+
+func languageNames() []string {
+	var out []string
+	for _, l := range langTable {
+		out = append(out, l.Names...)
+	}
+	return out
+}
+
+// namespaceFor resolves the namespace of a proto package: namespaceMap, then the derivation
+// rule when enabled, then the plain namespace.
+func (t exportTarget) namespaceFor(pkg string) string {
+	if ns, ok := t.NamespaceMap[pkg]; ok {
+		return ns
+	}
+	if t.DeriveNamespace && pkg != "" {
+		l, _ := lookupLang(t.Language)
+		if l.Derive != nil {
+			return l.Derive(t.NamespacePrefix, pkg)
+		}
+		return joinPackage(strings.Trim(t.NamespacePrefix+"."+pkg, "."), ".", true)
+	}
+	return t.Namespace
+}
+
+// fileOptions returns the file options of one output file: source options on the language's
+// allow-list, generated language options and export.fileOptions. Explicit config wins over source
+// values, which in turn win over derived defaults.
+func (t exportTarget) fileOptions(pf *PFile, fileName, sourcePath string) []fileOption {
+	l, ok := lookupLang(t.Language)
+	if !ok {
+		return nil
+	}
+	var out []fileOption
+	for _, o := range pf.Options {
+		if containsString(commonCarry, o.Name) || containsString(l.Carry, o.Name) {
+			out = setOption(out, o, true)
+		}
+	}
+	// 语言 option 按输出 package（经 packageRewrite/flattenPackage 改写后）计算
+	pkg := t.rewritePackage(pf.Package)
+	f := langFile{Package: pkg, Namespace: t.namespaceFor(pkg), FileName: fileName, SourcePath: sourcePath}
+	for _, o := range l.Options(f, t.LangOptions) {
+		out = setOption(out, o, !o.Derived)
+	}
+	for _, name := range sortedKeys(t.FileOptions) {
+		out = setOption(out, optionLiteral(name, t.FileOptions[name]), true)
+	}
+	return out
+}
+
+// setOption adds o to opts, replacing an option of the same name only when override is set.
+func setOption(opts []fileOption, o fileOption, override bool) []fileOption {
+	for i := range opts {
+		if opts[i].Name == o.Name {
+			if override {
+				opts[i] = o
+			}
+			return opts
+		}
+	}
+	return append(opts, o)
+}
+
+// optionLiteral renders a configured option value. Options of FileOptions are written as a string
+// literal when the field is a string and as is otherwise; custom options, whose type is unknown
+// here, are written as is for numbers and true/false and as a string otherwise.
+func optionLiteral(name, v string) fileOption {
+	v = strings.TrimSpace(v)
+	if fd := fileOptionFields.ByName(protoreflect.Name(name)); fd != nil {
+		if fd.Kind() == protoreflect.StringKind || fd.Kind() == protoreflect.BytesKind {
+			return strOption(name, v)
+		}
+		return fileOption{Name: name, Value: v}
+	}
+	if v == "true" || v == "false" || isNumber(v) {
+		return fileOption{Name: name, Value: v}
+	}
+	return strOption(name, v)
+}
+
+// isNumber reports whether s is a finite decimal number; NaN and Inf are not proto literals.
+func isNumber(s string) bool {
+	f, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func derived(o fileOption) fileOption {
+	o.Derived = true
+	return o
+}
+
+func writeFileOptions(b *strings.Builder, opts []fileOption) {
+	for i, o := range opts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("option " + o.Name + " = " + o.Value + ";")
+	}
+}
+
+func strOption(name, v string) fileOption {
+	return fileOption{Name: name, Value: "\"" + strings.ReplaceAll(strings.ReplaceAll(v, "\\", "\\\\"), "\"", "\\\"") + "\""}
+}
+
+// joinPackage joins the dotted segments of pkg with sep, capitalizing each segment when title is set.
+func joinPackage(pkg, sep string, title bool) string {
+	if pkg == "" {
+		return ""
+	}
+	parts := strings.Split(pkg, ".")
+	if title {
+		for i, p := range parts {
+			parts[i] = toCamel(p)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// objcPrefix derives a class prefix from the package initials, e.g. game.shared -> GS.
+func objcPrefix(pkg string) string {
+	var b strings.Builder
+	for _, p := range strings.Split(pkg, ".") {
+		if p != "" {
+			b.WriteRune(toUpper(rune(p[0])))
+		}
+	}
+	return b.String()
+}
+
+// swiftPrefix follows the swift-protobuf default, e.g. game.shared -> Game_Shared_.
+func swiftPrefix(pkg string) string {
+	return joinPackage(pkg, "_", true) + "_"
+}
+
+func removeNonIdent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isIdent(s[i]) {
+			b.WriteByte(s[i])
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGoPackage(t *testing.T) {
+	opts := LangOptions{
+		GoModule: "github.com/acme/pb/",
+		GoPackageMap: map[string]string{
+			"shared/structs.proto": "github.com/acme/common",
+			"game.v1":              "github.com/acme/game;gamev1",
+		},
+	}
+	tests := []struct {
+		f    langFile
+		opts LangOptions
+		out  string
+	}{
+		{langFile{Package: "cli", SourcePath: "cli/account.proto"}, opts, "github.com/acme/pb/cli;cli"},
+		{langFile{Package: "game.shared", SourcePath: "game/x.proto"}, opts, "github.com/acme/pb/game/shared;shared"},
+		{langFile{Package: "shared", SourcePath: "shared/structs.proto"}, opts, "github.com/acme/common;common"},
+		{langFile{Package: "game.v1", SourcePath: "game/v1.proto"}, opts, "github.com/acme/game;gamev1"},
+		{langFile{SourcePath: "root.proto"}, opts, "github.com/acme/pb;pb"},
+		{langFile{Package: "cli", Namespace: "example.com/pb"}, LangOptions{}, "example.com/pb"},
+		{langFile{Package: "cli"}, LangOptions{}, ""},
+	}
+	for _, tt := range tests {
+		if got := goPackage(tt.f, tt.opts); got != tt.out {
+			t.Errorf("goPackage(%+v) = %q, want %q", tt.f, got, tt.out)
+		}
+	}
+}
+
+func TestFileOptionsMerge(t *testing.T) {
+	pf := &PFile{Package: "fo", Options: []fileOption{
+		{Name: "optimize_for", Value: "SPEED"},
+		{Name: "java_package", Value: `"com.src"`},
+		{Name: "java_outer_classname", Value: `"SrcOuter"`},
+		{Name: "csharp_namespace", Value: `"Src.Fo"`},
+	}}
+	tests := []struct {
+		name   string
+		target exportTarget
+		want   []fileOption
+	}{
+		{"java source beats derived", exportTarget{Language: "java"}, []fileOption{
+			{Name: "optimize_for", Value: "SPEED"},
+			{Name: "java_package", Value: `"com.src"`},
+			{Name: "java_outer_classname", Value: `"SrcOuter"`},
+		}},
+		{"namespace beats source", exportTarget{Language: "java", Namespace: "com.ns"}, []fileOption{
+			{Name: "optimize_for", Value: "SPEED"},
+			{Name: "java_package", Value: `"com.ns"`},
+			{Name: "java_outer_classname", Value: `"SrcOuter"`},
+		}},
+		{"fileOptions beat all", exportTarget{Language: "csharp", Namespace: "Cfg", FileOptions: map[string]string{
+			"optimize_for": "LITE_RUNTIME", "csharp_namespace": "X.Y", "php_class_prefix": "P",
+		}}, []fileOption{
+			{Name: "optimize_for", Value: "LITE_RUNTIME"},
+			{Name: "csharp_namespace", Value: `"X.Y"`},
+			{Name: "php_class_prefix", Value: `"P"`},
+		}},
+		{"lua carries common only", exportTarget{Language: "lua"}, []fileOption{
+			{Name: "optimize_for", Value: "SPEED"},
+		}},
+	}
+	for _, tt := range tests {
+		if got := tt.target.fileOptions(pf, "a", "fo/a.proto"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageOptions(t *testing.T) {
+	pf := &PFile{Package: "game.shared"}
+	yes := true
+	tests := []struct {
+		target exportTarget
+		want   []string
+	}{
+		{exportTarget{Language: "csharp", Namespace: "Game.Proto"}, []string{`csharp_namespace = "Game.Proto"`}},
+		{exportTarget{Language: "csharp"}, nil},
+		{exportTarget{Language: "java", Namespace: "com.game"}, []string{`java_package = "com.game"`, `java_outer_classname = "ItemProto"`}},
+		{exportTarget{Language: "java", LangOptions: LangOptions{JavaMultipleFiles: &yes}}, []string{`java_package = "game.shared"`, "java_multiple_files = true", `java_outer_classname = "ItemProto"`}},
+		{exportTarget{Language: "kotlin"}, []string{`java_package = "game.shared"`, `java_outer_classname = "ItemProto"`}},
+		{exportTarget{Language: "objc", Namespace: "GPB"}, []string{`objc_class_prefix = "GPB"`}},
+		{exportTarget{Language: "objc", NamespaceMap: map[string]string{"game.shared": "GSH"}}, []string{`objc_class_prefix = "GSH"`}},
+		{exportTarget{Language: "objc"}, []string{`objc_class_prefix = "GS"`}},
+		{exportTarget{Language: "objc", Namespace: "Game.Proto", LangOptions: LangOptions{ObjcClassPrefix: "GAM"}}, []string{`objc_class_prefix = "GAM"`}},
+		{exportTarget{Language: "objc", DeriveNamespace: true, NamespacePrefix: "Acme"}, []string{`objc_class_prefix = "AcmeGS"`}},
+		{exportTarget{Language: "php", Namespace: "Game.Proto"}, []string{`php_namespace = "Game\\Proto"`}},
+		{exportTarget{Language: "php"}, []string{`php_namespace = "Game\\Shared"`}},
+		{exportTarget{Language: "ruby", Namespace: "Game.Proto"}, []string{`ruby_package = "Game::Proto"`}},
+		{exportTarget{Language: "rb"}, []string{`ruby_package = "Game::Shared"`}},
+		{exportTarget{Language: "swift", Namespace: "GP"}, []string{`swift_prefix = "GP"`}},
+		{exportTarget{Language: "swift", NamespaceMap: map[string]string{"game.shared": "Shared_"}}, []string{`swift_prefix = "Shared_"`}},
+		{exportTarget{Language: "swift"}, []string{`swift_prefix = "Game_Shared_"`}},
+		{exportTarget{Language: "swift", DeriveNamespace: true, NamespacePrefix: "Acme"}, []string{`swift_prefix = "Acme_Game_Shared_"`}},
+		{exportTarget{Language: "python", Namespace: "Game.Proto"}, nil},
+		{exportTarget{Language: "lua", Namespace: "Game.Proto"}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, o := range tt.target.fileOptions(pf, "item", "game/item.proto") {
+			got = append(got, o.Name+" = "+o.Value)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %+v: got %q, want %q", tt.target.Language, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestOptionLiteral(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"optimize_for", "LITE_RUNTIME", "LITE_RUNTIME"},
+		{"cc_enable_arenas", "true", "true"},
+		{"java_multiple_files", " false ", "false"},
+		{"java_package", "1", `"1"`},
+		{"swift_prefix", "true", `"true"`},
+		{"php_class_prefix", "NaN", `"NaN"`},
+		{"go_package", `a"b`, `"a\"b"`},
+		{"(acme.level)", "3", "3"},
+		{"(acme.enabled)", "true", "true"},
+		{"(acme.label)", "x", `"x"`},
+		{"(acme.label)", "NaN", `"NaN"`},
+	}
+	for _, tt := range tests {
+		if got := optionLiteral(tt.name, tt.value).Value; got != tt.want {
+			t.Errorf("optionLiteral(%q, %q) = %s, want %s", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
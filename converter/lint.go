@@ -0,0 +1,396 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// LintFinding is one lint rule violation.
+type LintFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Element  string   `json:"element,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	pos := f.File
+	if f.Line > 0 {
+		pos = fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	}
+	return fmt.Sprintf("%s: %s [%s] %s: %s", pos, f.Severity, f.Rule, f.Element, f.Message)
+}
+
+// lintSeverity is the severity of each rule, keyed by its yaml name in LintRules.
+var lintSeverity = map[string]Severity{
+	"fieldNumberDuplicate": SeverityError,
+	"fieldNumberRange":     SeverityError,
+	"enumZeroValue":        SeverityError,
+	"enumValuePrefix":      SeverityWarning,
+	"namingConvention":     SeverityWarning,
+	"duplicateDefinition":  SeverityError,
+	"mapKeyType":           SeverityError,
+}
+
+// enabled reports whether rule is on; unset rules are enabled.
+func (r LintRules) enabled(rule string) bool {
+	f, ok := yamlFields(reflect.TypeOf(r))[rule]
+	if !ok {
+		return false
+	}
+	v := reflect.ValueOf(r).FieldByIndex(f.Index)
+	return v.IsNil() || v.Elem().Bool()
+}
+
+// Lint exports into a scratch directory and lints the reachable source files and the exported
+// files with the rules of the lint section. The report is written to w in the configured
+// format; failed is set when a finding has error severity.
+func (e *Exporter) Lint(w io.Writer) (failed bool, err error) {
+	cfg, err := readProtoConfig(e.ConfigPath, e.Env, e.Set)
+	if err != nil {
+		return false, err
+	}
+	lc := cfg.Lint
+	tmp, err := os.MkdirTemp("", "proto-converter-lint-")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmp)
+	var dirs, sources []string
+	var styles []lintStyle
+	err = e.run(runHooks{
+		target: func(i int, t *exportTarget) {
+			dirs = append(dirs, t.Dir)
+			styles = append(styles, lintStyle{types: t.style(t.TypeNameCase), fields: t.style(t.FieldNameCase), values: t.style(t.EnumValueCase), stripPrefix: t.StripEnumPrefix})
+			t.Dir = filepath.Join(tmp, strconv.Itoa(i))
+			t.DescriptorSet, t.DescriptorJSON, t.JSONSchema = "", "", ""
+		},
+		sources: func(paths []string) { sources = paths },
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var findings []LintFinding
+	if lc.Sources == nil || *lc.Sources {
+		files := map[string]string{}
+		for _, p := range sources {
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return false, err
+			}
+			files[p] = string(b)
+		}
+		out, err := lintFiles(files, lc.Rules, lintStyle{})
+		if err != nil {
+			return false, err
+		}
+		findings = append(findings, out...)
+	}
+	if lc.Output == nil || *lc.Output {
+		for i, dir := range dirs {
+			files := map[string]string{}
+			root := filepath.Join(tmp, strconv.Itoa(i))
+			err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+				if err != nil || d.IsDir() || filepath.Ext(path) != ".proto" {
+					return err
+				}
+				b, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(root, path)
+				files[shortPath(filepath.Join(dir, rel))] = string(b)
+				return nil
+			})
+			if err != nil {
+				return false, err
+			}
+			out, err := lintFiles(files, lc.Rules, styles[i])
+			if err != nil {
+				return false, err
+			}
+			findings = append(findings, out...)
+		}
+	}
+
+	count := map[Severity]int{}
+	for _, f := range findings {
+		count[f.Severity]++
+	}
+	if strings.EqualFold(lc.Format, "json") {
+		if findings == nil {
+			findings = []LintFinding{}
+		}
+		b, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return count[SeverityError] > 0, err
+	}
+	for _, f := range findings {
+		fmt.Fprintln(w, f)
+	}
+	fmt.Fprintf(w, "共 %d 处问题（error %d，warning %d）\n", len(findings), count[SeverityError], count[SeverityWarning])
+	return count[SeverityError] > 0, nil
+}
+
+// lintStyle holds the expected naming of types, fields and enum values. A keep style falls back
+// to the protobuf style guide. stripPrefix marks output exported with stripEnumPrefix, whose enum
+// values no longer start with the enum name.
+type lintStyle struct {
+	types, fields, values caseStyle
+	stripPrefix           bool
+}
+
+var (
+	pascalName    = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	snakeName     = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	upperSnake    = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+	packageName   = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+	screamingCase = caseStyle{Kind: "SCREAMING_SNAKE"}
+)
+
+// conforms checks name against style, or against the style guide pattern re under keep.
+func conforms(style caseStyle, re *regexp.Regexp, guide, name string) (string, bool) {
+	if style.keep() {
+		return guide, re.MatchString(name)
+	}
+	return style.Kind, style.apply(name) == name
+}
+
+// lintFiles lints proto sources keyed by the path they are reported under.
+func lintFiles(sources map[string]string, rules LintRules, style lintStyle) ([]LintFinding, error) {
+	l := &linter{rules: rules, style: style}
+	defined := map[string]string{}
+	for _, name := range sortedKeys(sources) {
+		p, err := parseDescriptor(name, sources[name])
+		if err != nil {
+			return nil, err
+		}
+		l.file, l.fd, l.spans = name, p.fd, map[string][]int32{}
+		for _, loc := range p.locs {
+			l.spans[fmt.Sprint(loc.Path)] = loc.Span
+		}
+		l.lintFile()
+		// 同一 package 的顶层定义不得跨文件（或在同一文件内）重复
+		scope := ""
+		if p.fd.GetPackage() != "" {
+			scope = p.fd.GetPackage() + "."
+		}
+		var tops []string
+		var paths [][]int32
+		for i, m := range p.fd.MessageType {
+			tops, paths = append(tops, m.GetName()), append(paths, []int32{4, int32(i)})
+		}
+		for i, en := range p.fd.EnumType {
+			tops, paths = append(tops, en.GetName()), append(paths, []int32{5, int32(i)})
+		}
+		for i, s := range p.fd.Service {
+			tops, paths = append(tops, s.GetName()), append(paths, []int32{6, int32(i)})
+		}
+		for i, top := range tops {
+			full := scope + top
+			if prev, ok := defined[full]; ok {
+				l.report("duplicateDefinition", paths[i], full, "已在 %s 中定义", prev)
+				continue
+			}
+			defined[full] = name
+		}
+	}
+	return l.out, nil
+}
+
+// linter walks one parsed file at a time.
+type linter struct {
+	rules LintRules
+	style lintStyle
+	file  string
+	fd    *descriptorpb.FileDescriptorProto
+	spans map[string][]int32
+	out   []LintFinding
+}
+
+func (l *linter) report(rule string, path []int32, element, format string, args ...any) {
+	if !l.rules.enabled(rule) {
+		return
+	}
+	f := LintFinding{Rule: rule, Severity: lintSeverity[rule], File: l.file, Element: element, Message: fmt.Sprintf(format, args...)}
+	if span, ok := l.spans[fmt.Sprint(path)]; ok {
+		f.Line, f.Column = int(span[0])+1, int(span[1])+1
+	}
+	l.out = append(l.out, f)
+}
+
+func (l *linter) lintFile() {
+	scope := ""
+	if pkg := l.fd.GetPackage(); pkg != "" {
+		if !packageName.MatchString(pkg) {
+			l.report("namingConvention", []int32{2}, pkg, "package 名称应为小写并以点分隔")
+		}
+		scope = pkg + "."
+	}
+	// proto3 的枚举为开放枚举，proto2 为封闭枚举；editions 默认开放，可由 features.enum_type 逐层覆盖
+	open := l.fd.GetSyntax() == "proto3"
+	if l.fd.GetSyntax() == "editions" {
+		open = enumOpen(true, l.fd.GetOptions().GetFeatures())
+	}
+	for i, m := range l.fd.MessageType {
+		l.message(m, scope, []int32{4, int32(i)}, open)
+	}
+	for i, en := range l.fd.EnumType {
+		l.enum(en, scope, []int32{5, int32(i)}, open)
+	}
+	for i, f := range l.fd.Extension {
+		l.fieldNumber(f, scope+f.GetName(), []int32{7, int32(i)})
+	}
+	for i, s := range l.fd.Service {
+		path := []int32{6, int32(i)}
+		l.typeName(s.GetName(), scope+s.GetName(), path, "服务")
+		for j, m := range s.Method {
+			l.typeName(m.GetName(), scope+s.GetName()+"."+m.GetName(), append(append([]int32(nil), path...), 2, int32(j)), "方法")
+		}
+	}
+}
+
+func (l *linter) typeName(name, element string, path []int32, kind string) {
+	if want, ok := conforms(l.style.types, pascalName, "PascalCase", name); !ok {
+		l.report("namingConvention", path, element, "%s名称应为 %s", kind, want)
+	}
+}
+
+// message checks m and its nested definitions; open tells whether enums inherit open semantics.
+func (l *linter) message(m *descriptorpb.DescriptorProto, scope string, path []int32, open bool) {
+	full := scope + m.GetName()
+	open = enumOpen(open, m.GetOptions().GetFeatures())
+	l.typeName(m.GetName(), full, path, "消息")
+	byNum := map[int32]string{}
+	synthetic := map[int32]bool{}
+	for j, f := range m.Field {
+		fpath := append(append([]int32(nil), path...), 2, int32(j))
+		el := full + "." + f.GetName()
+		if prev, ok := byNum[f.GetNumber()]; ok {
+			l.report("fieldNumberDuplicate", fpath, el, "编号 %d 已被字段 %s 使用", f.GetNumber(), prev)
+		} else {
+			byNum[f.GetNumber()] = f.GetName()
+		}
+		l.fieldNumber(f, el, fpath)
+		if f.GetType() != descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+			if want, ok := conforms(l.style.fields, snakeName, "lower_snake_case", f.GetName()); !ok {
+				l.report("namingConvention", fpath, el, "字段名称应为 %s", want)
+			}
+		}
+		if f.GetProto3Optional() {
+			synthetic[f.GetOneofIndex()] = true
+		}
+		if entry := mapEntryOf(m, f); entry != nil && len(entry.Field) > 0 {
+			key := entry.Field[0]
+			if !validMapKey(key) {
+				l.report("mapKeyType", fpath, el, "map 的 key 不能是 %s，只能是整数类型、bool 或 string", fieldTypeName(key))
+			}
+		}
+	}
+	for k, o := range m.OneofDecl {
+		if synthetic[int32(k)] {
+			continue
+		}
+		if !snakeName.MatchString(o.GetName()) {
+			l.report("namingConvention", append(append([]int32(nil), path...), 8, int32(k)), full+"."+o.GetName(), "oneof 名称应为 lower_snake_case")
+		}
+	}
+	for j, f := range m.Extension {
+		l.fieldNumber(f, full+"."+f.GetName(), append(append([]int32(nil), path...), 6, int32(j)))
+	}
+	for k, nested := range m.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			continue
+		}
+		l.message(nested, full+".", append(append([]int32(nil), path...), 3, int32(k)), open)
+	}
+	for k, en := range m.EnumType {
+		l.enum(en, full+".", append(append([]int32(nil), path...), 4, int32(k)), open)
+	}
+}
+
+// enumOpen applies features.enum_type of one scope to the inherited open semantics.
+func enumOpen(inherited bool, f *descriptorpb.FeatureSet) bool {
+	switch f.GetEnumType() {
+	case descriptorpb.FeatureSet_OPEN:
+		return true
+	case descriptorpb.FeatureSet_CLOSED:
+		return false
+	}
+	return inherited
+}
+
+// fieldNumber checks that a field number is valid and outside the range reserved for the
+// protobuf implementation.
+func (l *linter) fieldNumber(f *descriptorpb.FieldDescriptorProto, el string, path []int32) {
+	switch n := f.GetNumber(); {
+	case n < 1 || n > 536870911:
+		l.report("fieldNumberRange", path, el, "编号 %d 超出 1..536870911", n)
+	case n >= 19000 && n <= 19999:
+		l.report("fieldNumberRange", path, el, "编号 %d 位于 protobuf 实现保留的 19000-19999", n)
+	}
+}
+
+// mapEntryOf returns the synthesized map entry message of a map field f of m, or nil.
+func mapEntryOf(m *descriptorpb.DescriptorProto, f *descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	if f.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED || f.GetTypeName() == "" {
+		return nil
+	}
+	name := f.GetTypeName()[strings.LastIndexByte(f.GetTypeName(), '.')+1:]
+	for _, nested := range m.NestedType {
+		if nested.GetName() == name && nested.GetOptions().GetMapEntry() {
+			return nested
+		}
+	}
+	return nil
+}
+
+func validMapKey(f *descriptorpb.FieldDescriptorProto) bool {
+	// 未解析的类型名（message 或 enum）同样无效
+	if f.Type == nil {
+		return false
+	}
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptorpb.FieldDescriptorProto_TYPE_BYTES, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptorpb.FieldDescriptorProto_TYPE_GROUP, descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		return false
+	}
+	return true
+}
+
+func (l *linter) enum(en *descriptorpb.EnumDescriptorProto, scope string, path []int32, open bool) {
+	full := scope + en.GetName()
+	l.typeName(en.GetName(), full, path, "枚举")
+	if enumOpen(open, en.GetOptions().GetFeatures()) && (len(en.Value) == 0 || en.Value[0].GetNumber() != 0) {
+		l.report("enumZeroValue", path, full, "开放枚举（proto3 或 editions 的 OPEN）的第一个值必须为 0")
+	}
+	prefix := screamingCase.apply(en.GetName()) + "_"
+	for j, v := range en.Value {
+		vpath := append(append([]int32(nil), path...), 2, int32(j))
+		el := full + "." + v.GetName()
+		if want, ok := conforms(l.style.values, upperSnake, "UPPER_SNAKE_CASE", v.GetName()); !ok {
+			l.report("namingConvention", vpath, el, "枚举值名称应为 %s", want)
+		}
+		// 先统一为 SCREAMING_SNAKE，导出时改过大小写风格的枚举值同样适用
+		if !l.style.stripPrefix && !strings.HasPrefix(screamingCase.apply(v.GetName())+"_", prefix) {
+			l.report("enumValuePrefix", vpath, el, "枚举值应以 %s 开头", prefix)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestLintFiles(t *testing.T) {
+	sources := map[string]string{
+		"a.proto": `syntax = "proto3";
+package demo;
+message user_info {
+  int32 id = 1;
+  string Name = 1;
+  int32 internal = 19001;
+  map<double, string> by_score = 3;
+  map<string, Status> by_name = 4;
+}
+enum Status {
+  ACTIVE = 1;
+  STATUS_GONE = 2;
+}
+`,
+		"b.proto": `syntax = "proto3";
+package demo;
+message Dup { int32 a = 1; }
+enum Status { STATUS_UNKNOWN = 0; }
+`,
+	}
+	off := false
+	tests := []struct {
+		name  string
+		rules LintRules
+		style lintStyle
+		want  []string
+	}{
+		{"all rules", LintRules{}, lintStyle{}, []string{
+			"a.proto:3:1 namingConvention demo.user_info",
+			"a.proto:5:3 fieldNumberDuplicate demo.user_info.Name",
+			"a.proto:5:3 namingConvention demo.user_info.Name",
+			"a.proto:6:3 fieldNumberRange demo.user_info.internal",
+			"a.proto:7:3 mapKeyType demo.user_info.by_score",
+			"a.proto:10:1 enumZeroValue demo.Status",
+			"a.proto:11:3 enumValuePrefix demo.Status.ACTIVE",
+			"b.proto:4:1 duplicateDefinition demo.Status",
+		}},
+		{"disabled", LintRules{NamingConvention: &off, EnumValuePrefix: &off, DuplicateDefinition: &off, FieldNumberRange: &off}, lintStyle{}, []string{
+			"a.proto:5:3 fieldNumberDuplicate demo.user_info.Name",
+			"a.proto:7:3 mapKeyType demo.user_info.by_score",
+			"a.proto:10:1 enumZeroValue demo.Status",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := lintFiles(sources, tt.rules, tt.style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.File+":"+strconv.Itoa(f.Line)+":"+strconv.Itoa(f.Column)+" "+f.Rule+" "+f.Element)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintNamingStyle(t *testing.T) {
+	src := map[string]string{"c.proto": `syntax = "proto3";
+message Item { int32 itemId = 1; int32 item_count = 2; }
+enum Kind { KindNone = 0; }
+`}
+	style := lintStyle{fields: caseStyle{Kind: "lowerCamel"}, values: caseStyle{Kind: "camel"}}
+	findings, err := lintFiles(src, LintRules{}, style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Rule+" "+f.Element)
+	}
+	want := []string{"namingConvention Item.item_count"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+}
+
+func TestLintEnumZeroValue(t *testing.T) {
+	sources := map[string]string{
+		"p2.proto": `syntax = "proto2";
+enum P2 { P2_ONE = 1; }
+`,
+		"open.proto": `edition = "2023";
+package open;
+enum Open { OPEN_ONE = 1; }
+message M {
+  option features.enum_type = CLOSED;
+  enum Closed { CLOSED_ONE = 1; }
+  enum Reopened {
+    option features.enum_type = OPEN;
+    REOPENED_ONE = 1;
+  }
+}
+`,
+		"closed.proto": `edition = "2023";
+package closed;
+option features.enum_type = CLOSED;
+enum Closed { CLOSED_ONE = 1; }
+message M { enum Nested { NESTED_ONE = 1; } }
+`,
+	}
+	findings, err := lintFiles(sources, LintRules{}, lintStyle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		if f.Rule == "enumZeroValue" {
+			got = append(got, f.Element)
+		}
+	}
+	want := []string{"open.M.Reopened", "open.Open"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("enumZeroValue findings = %q, want %q", got, want)
+	}
+}
+
+func TestLintStrippedEnumPrefix(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/kind.proto": "syntax = \"proto3\";\npackage game;\nenum Kind {\n  KIND_NONE = 0;\n  KIND_ITEM = 1;\n}\n",
+		"cfg.yaml":         "import:\n  dir: proto\n  keep:\n    files:\n      - file: kind\nexport:\n  dir: out\n  language: csharp\n  stripEnumPrefix: true\nlint:\n  format: json\n",
+	})
+	// 源文件仍按前缀检查，去掉前缀的导出结果不检查
+	var buf bytes.Buffer
+	if _, err := (&Exporter{ConfigPath: "cfg.yaml"}).Lint(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var findings []LintFinding
+	if err := json.Unmarshal(buf.Bytes(), &findings); err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("findings = %+v, want none", findings)
+	}
+	src := map[string]string{"k.proto": "syntax = \"proto3\";\nenum Kind { NONE = 0; }\n"}
+	if got, _ := lintFiles(src, LintRules{}, lintStyle{}); len(got) != 1 || got[0].Rule != "enumValuePrefix" {
+		t.Errorf("sources: findings = %+v, want one enumValuePrefix", got)
+	}
+}
@@ -0,0 +1,202 @@
+package converter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// descriptorOptions are the google.protobuf.*Options messages that custom options extend.
+var descriptorOptions = []string{
+	"FileOptions", "MessageOptions", "FieldOptions", "OneofOptions", "EnumOptions",
+	"EnumValueOptions", "ServiceOptions", "MethodOptions", "ExtensionRangeOptions",
+}
+
+var (
+	reOptionList = regexp.MustCompile(`=\s*-?(?:0[xX][0-9A-Fa-f]+|\d+)\s*\[`)
+	reOptionStmt = regexp.MustCompile(`\boption\s*\(\s*(\.?[A-Za-z_][\w\.]*)\s*\)`)
+)
+
+// isOptionsExtend reports whether d is a top-level extend of a google.protobuf.*Options message,
+// i.e. a custom option definition.
+func isOptionsExtend(d *TopDef) bool {
+	if d.Kind != "extend" {
+		return false
+	}
+	target := strings.TrimPrefix(extendTarget(d), ".")
+	name, ok := strings.CutPrefix(target, "google.protobuf.")
+	return ok && containsString(descriptorOptions, name)
+}
+
+// optionNames lists the custom option names used in def, e.g. validate.rules for
+// [(validate.rules).int32.gt = 0] and msg_id for option (msg_id) = 1001;.
+func optionNames(def string) []string {
+	s := stripComments(def)
+	seen := map[string]struct{}{}
+	for _, m := range reRefOption.FindAllStringSubmatch(s, -1) {
+		seen[m[1]] = struct{}{}
+	}
+	out := make([]string, 0, len(seen))
+	for n := range seen {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// optionScopes lists the fully qualified candidates of a custom option name used in package pkg,
+// innermost scope first, following protobuf's name resolution.
+func optionScopes(pkg, name string) []string {
+	if strings.HasPrefix(name, ".") {
+		return []string{name[1:]}
+	}
+	var out []string
+	for scope := pkg; ; {
+		out = append(out, qualify(scope, "", name))
+		if scope == "" {
+			break
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+	return out
+}
+
+// matchNamespace reports whether a fully qualified option name lies in one of namespaces.
+func matchNamespace(name string, namespaces []string) bool {
+	name = strings.TrimPrefix(name, ".")
+	for _, ns := range namespaces {
+		ns = strings.Trim(ns, ".")
+		if ns != "" && (name == ns || strings.HasPrefix(name, ns+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripOptionUses removes the custom options for which drop(name) is true: entries of field and
+// enum value option lists ([(name).x = v]) and option statements (option (name) = v;).
+func stripOptionUses(def string, drop func(name string) bool) string {
+	// 选项语句：从行首删除到语句结束的分号
+	for _, loc := range reverse(reOptionStmt.FindAllStringSubmatchIndex(def, -1)) {
+		if inComment(def, loc[0]) || !drop(def[loc[2]:loc[3]]) {
+			continue
+		}
+		end := scanTo(def, loc[1], ';')
+		if end < 0 {
+			continue
+		}
+		start, stop := loc[0], end+1
+		if ls := strings.LastIndexByte(def[:start], '\n'); strings.TrimSpace(def[ls+1:start]) == "" {
+			start = ls + 1
+			if stop < len(def) && def[stop] == '\n' {
+				stop++
+			}
+		}
+		def = def[:start] + def[stop:]
+	}
+	// 字段与枚举值的选项列表：逐项删除，列表为空时连同方括号一起删除
+	for _, loc := range reverse(reOptionList.FindAllStringIndex(def, -1)) {
+		open := loc[1] - 1
+		if inComment(def, open) {
+			continue
+		}
+		end := closingBracket(def[open:])
+		if end < 0 {
+			continue
+		}
+		end += open
+		var kept []string
+		for _, entry := range splitTopLevel(def[open+1:end], ',') {
+			e := strings.TrimSpace(entry)
+			if strings.HasPrefix(e, "(") {
+				if close := strings.IndexByte(e, ')'); close > 0 && drop(strings.TrimSpace(e[1:close])) {
+					continue
+				}
+			}
+			if e != "" {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			start := open
+			for start > 0 && (def[start-1] == ' ' || def[start-1] == '\t') {
+				start--
+			}
+			def = def[:start] + def[end+1:]
+			continue
+		}
+		def = def[:open+1] + strings.Join(kept, ", ") + def[end:]
+	}
+	return def
+}
+
+// splitTopLevel splits s at sep outside quotes, braces, brackets and parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var out []string
+	depth, last := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{' || c == '[' || c == '(' || c == '<':
+			depth++
+		case c == '}' || c == ']' || c == ')' || c == '>':
+			depth--
+		case c == sep && depth == 0:
+			out = append(out, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(out, s[last:])
+}
+
+// scanTo returns the index of the first c at or after i outside quotes and braces, or -1.
+func scanTo(s string, i int, c byte) int {
+	depth := 0
+	var quote byte
+	for ; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+		case ch == c && depth == 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// inComment reports whether position i of s lies in a // line comment.
+func inComment(s string, i int) bool {
+	ls := strings.LastIndexByte(s[:i], '\n')
+	return strings.Contains(s[ls+1:i], "//")
+}
+
+func reverse[T any](in []T) []T {
+	out := make([]T, len(in))
+	for i, v := range in {
+		out[len(in)-1-i] = v
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStripOptionUses(t *testing.T) {
+	def := `message Hero {
+  option (gogoproto.goproto_getters) = false;
+  option (msg_id) = 1001;
+  int32 hp = 1 [(validate.rules).int32.gt = 0];
+  string name = 2 [deprecated = true, (validate.rules).string = { min_len: 1, max_len: 8 }];
+  // option (validate.disabled) = true;
+}`
+	want := `message Hero {
+  option (msg_id) = 1001;
+  int32 hp = 1;
+  string name = 2 [deprecated = true];
+  // option (validate.disabled) = true;
+}`
+	got := stripOptionUses(def, func(name string) bool { return matchNamespace(name, []string{"validate", "gogoproto"}) })
+	if got != want {
+		t.Errorf("stripOptionUses =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOptionScopes(t *testing.T) {
+	tests := []struct {
+		pkg, name string
+		out       []string
+	}{
+		{"game.hero", "msg_id", []string{"game.hero.msg_id", "game.msg_id", "msg_id"}},
+		{"game", "validate.rules", []string{"game.validate.rules", "validate.rules"}},
+		{"game", ".validate.rules", []string{"validate.rules"}},
+		{"", "msg_id", []string{"msg_id"}},
+	}
+	for _, tt := range tests {
+		if got := optionScopes(tt.pkg, tt.name); !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("optionScopes(%q, %q) = %v, want %v", tt.pkg, tt.name, got, tt.out)
+		}
+	}
+}
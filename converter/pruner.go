@@ -3,6 +3,7 @@ package converter
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -16,10 +17,16 @@ type PFile struct {
 	Path    string
 	Package string
 	Syntax  string
+	// Edition is set for Protobuf Editions files (edition = "2023"), whose Syntax is "editions".
+	Edition string
+	// Options are the file-level options in source order.
+	Options []fileOption
+	// Imports are the import statements in source order, including public and weak ones.
+	Imports []protoImport
 	Defs    []TopDef
 }
 
-// TopDef is a top-level definition block (message/enum) with references.
+// TopDef is a top-level definition block (message/enum/service) with references.
 type TopDef struct {
 	Kind string
 	Name string
@@ -27,24 +34,25 @@ type TopDef struct {
 	Refs []string
 }
 
-// BuildPrunedTempProtos prunes and writes proto files based on seeds and keep rules.
-func (Pruner) BuildPrunedTempProtos(
-	all []protoItem,
-	seeds []protoItem,
-	seedKeep map[string]map[string]struct{},
-	typeFieldKeep map[string]map[string]struct{},
-	inDir, outDir, ns, lang, caseKind, fieldNameCase string,
-	dry bool,
-) (string, []protoItem, error) {
+// ParseAll parses every proto item, keyed by slash-separated path.
+func (Pruner) ParseAll(all []protoItem) (map[string]*PFile, error) {
 	parsed := map[string]*PFile{}
-	pkgs := map[string]struct{}{}
 	for _, it := range all {
-		p := filepath.ToSlash(it.Path)
 		pf, err := parseProtoFile(it.Path)
 		if err != nil {
-			return "", nil, fmt.Errorf("解析 proto 失败: %s: %w", it.Path, err)
+			return nil, fmt.Errorf("解析 proto 失败: %s: %w", it.Path, err)
 		}
-		parsed[p] = pf
+		parsed[filepath.ToSlash(it.Path)] = pf
+	}
+	return parsed, nil
+}
+
+// BuildPrunedTempProtos prunes and writes proto files based on seeds and keep rules.
+func (Pruner) BuildPrunedTempProtos(parsed map[string]*PFile, t exportTarget, inDir string, dry bool) (string, []protoItem, error) {
+	seeds, seedKeep, typeFieldKeep := t.Keep.Seeds, t.Keep.SeedKeep, t.Keep.TypeFieldKeep
+	outDir, fileCase := t.Dir, t.style(t.FileNameCase)
+	pkgs := map[string]struct{}{}
+	for _, pf := range parsed {
 		if pf.Package != "" {
 			pkgs[pf.Package] = struct{}{}
 		}
@@ -66,6 +74,77 @@ func (Pruner) BuildPrunedTempProtos(
 		}
 	}
 
+	// import 路径与 DepResolver 一致，依次相对导入方所在目录、import.dir 与工作目录解析；
+	// 经 import public 转出的文件对导入方同样可见
+	cleanKeys := map[string]string{}
+	for key := range parsed {
+		cleanKeys[path.Clean(key)] = key
+	}
+	importedFile := func(from, imp string) (string, bool) {
+		for _, base := range []string{path.Dir(from), filepath.ToSlash(inDir), "."} {
+			if key, ok := cleanKeys[path.Join(base, imp)]; ok {
+				return key, true
+			}
+		}
+		return "", false
+	}
+	visibleFiles := map[string][]string{}
+	visible := func(file string) []string {
+		if v, ok := visibleFiles[file]; ok {
+			return v
+		}
+		seen := map[string]struct{}{file: {}}
+		out := []string{file}
+		var walk func(f string, publicOnly bool)
+		walk = func(f string, publicOnly bool) {
+			for _, imp := range parsed[f].Imports {
+				if publicOnly && imp.Kind != "public" {
+					continue
+				}
+				if key, ok := importedFile(f, imp.Path); ok {
+					if _, dup := seen[key]; !dup {
+						seen[key] = struct{}{}
+						out = append(out, key)
+						walk(key, true)
+					}
+				}
+			}
+		}
+		walk(file, false)
+		visibleFiles[file] = out
+		return out
+	}
+
+	// 自定义 option 索引：扩展字段全名 -> 定义它的 extend google.protobuf.*Options
+	optIndex := map[string]defRef{}
+	for filePath, pf := range parsed {
+		for i := range pf.Defs {
+			if d := &pf.Defs[i]; isOptionsExtend(d) {
+				for _, f := range messageFieldNames(d.Text) {
+					optIndex[qualify(pf.Package, "", f)] = defRef{File: filePath, Def: d}
+				}
+			}
+		}
+	}
+	resolveOption := func(curPkg, name string) (defRef, string, bool) {
+		for _, fqn := range optionScopes(curPkg, name) {
+			if dr, ok := optIndex[fqn]; ok {
+				return dr, fqn, true
+			}
+		}
+		return defRef{}, "", false
+	}
+	// dropOption 判断 option 是否属于 export.stripOptions 中的命名空间（按写法与解析后的全名匹配）
+	dropOption := func(curPkg string) func(name string) bool {
+		return func(name string) bool {
+			if matchNamespace(name, t.StripOptions) {
+				return true
+			}
+			_, fqn, ok := resolveOption(curPkg, name)
+			return ok && matchNamespace(fqn, t.StripOptions)
+		}
+	}
+
 	seedSet := map[string]struct{}{}
 	for _, s := range seeds {
 		seedSet[filepath.ToSlash(s.Path)] = struct{}{}
@@ -82,14 +161,22 @@ func (Pruner) BuildPrunedTempProtos(
 		if _, ok := set[d.Name]; ok {
 			return
 		}
+		// 被 stripOptions 移除的命名空间中的 option 定义不再输出
+		if isOptionsExtend(d) && len(t.StripOptions) > 0 {
+			stripped := true
+			for _, f := range messageFieldNames(d.Text) {
+				stripped = stripped && matchNamespace(qualify(parsed[file].Package, "", f), t.StripOptions)
+			}
+			if stripped {
+				return
+			}
+		}
 		set[d.Name] = struct{}{}
 		queue = append(queue, defRef{File: file, Def: d})
 	}
 	for filePath, pf := range parsed {
 		if _, isSeed := seedSet[filePath]; isSeed {
-			keepPath, _ := filepath.Rel(inDir, filePath)
-			keepPath = filepath.ToSlash(keepPath)
-			if keepSet, ok := seedKeep[keepPath]; ok {
+			if keepSet, ok := seedKeep[seedKeepPath(inDir, filePath)]; ok {
 				for i := range pf.Defs {
 					if _, ok := keepSet[pf.Defs[i].Name]; ok {
 						addDef(filePath, &pf.Defs[i])
@@ -121,6 +208,9 @@ func (Pruner) BuildPrunedTempProtos(
 		"google.protobuf.FloatValue":  "google/protobuf/wrappers.proto",
 		"google.protobuf.DoubleValue": "google/protobuf/wrappers.proto",
 	}
+	for _, name := range descriptorOptions {
+		wellKnown["google.protobuf."+name] = "google/protobuf/descriptor.proto"
+	}
 	scalar := map[string]struct{}{"double": {}, "float": {}, "int32": {}, "int64": {}, "uint32": {}, "uint64": {}, "sint32": {}, "sint64": {}, "fixed32": {}, "fixed64": {}, "sfixed32": {}, "sfixed64": {}, "bool": {}, "string": {}, "bytes": {}}
 
 	resolveTop := func(curPkg, token string) (string, bool) {
@@ -176,12 +266,67 @@ func (Pruner) BuildPrunedTempProtos(
 		if fqn, ok := resolveTop(curPkg, t); ok {
 			return index[fqn], true
 		}
+		// 相对引用的嵌套类型（Outer.Inner）归属于其顶层定义 Outer
+		if dot := strings.Index(t, "."); dot > 0 {
+			first := t[:dot]
+			if pf := parsed[curFile]; pf != nil {
+				for i := range pf.Defs {
+					if pf.Defs[i].Name == first {
+						return defRef{File: curFile, Def: &pf.Defs[i]}, true
+					}
+				}
+			}
+			if dr, ok := index[curPkg+"."+first]; ok && curPkg != "" {
+				return dr, true
+			}
+		}
+		// 在可见文件中按作用域由内向外查找（覆盖无 package 的文件与外层 package 中的类型）
+		for _, fqn := range optionScopes(curPkg, t) {
+			for _, f := range visible(curFile) {
+				pf := parsed[f]
+				rest := fqn
+				if pf.Package != "" {
+					if !strings.HasPrefix(fqn, pf.Package+".") {
+						continue
+					}
+					rest = fqn[len(pf.Package)+1:]
+				}
+				if dot := strings.Index(rest, "."); dot > 0 {
+					rest = rest[:dot]
+				}
+				for i := range pf.Defs {
+					if pf.Defs[i].Name == rest {
+						return defRef{File: f, Def: &pf.Defs[i]}, true
+					}
+				}
+			}
+		}
 		if lst, ok := simpleIndex[base]; ok && len(lst) == 1 {
 			return lst[0], true
 		}
 		return defRef{}, false
 	}
 
+	// 顶层 extend 在被扩展的类型入选后随之保留；新选中的定义可能又被其它 extend 扩展，故循环至不动点
+	extendsOf := func() {
+		for _, filePath := range sortedKeys(parsed) {
+			pf := parsed[filePath]
+			for i := range pf.Defs {
+				d := &pf.Defs[i]
+				if d.Kind != "extend" {
+					continue
+				}
+				if _, ok := selected[filePath][d.Name]; ok {
+					continue
+				}
+				if dr, ok := resolveDef(filePath, pf.Package, extendTarget(d)); ok {
+					if _, ok := selected[dr.File][dr.Def.Name]; ok {
+						addDef(filePath, d)
+					}
+				}
+			}
+		}
+	}
 	for len(queue) > 0 {
 		cur := queue[0]
 		queue = queue[1:]
@@ -194,11 +339,45 @@ func (Pruner) BuildPrunedTempProtos(
 		if keepSet := resolveTypeKeepSet(typeFieldKeep, curPkg, cur.Def.Name); keepSet != nil && strings.TrimSpace(cur.Def.Kind) == "message" {
 			defTxt = pruneMessageFields(defTxt, keepSet)
 		}
+		defTxt = stripOptionUses(defTxt, dropOption(curPkg))
 		for _, tok := range collectTypeTokens(defTxt) {
 			if dr, ok := resolveDef(cur.File, curPkg, tok); ok {
 				addDef(dr.File, dr.Def)
 			}
 		}
+		for _, name := range optionNames(defTxt) {
+			if dr, _, ok := resolveOption(curPkg, name); ok {
+				addDef(dr.File, dr.Def)
+			}
+		}
+		if len(queue) == 0 {
+			extendsOf()
+		}
+	}
+
+	// option 定义及其值类型：字段名与枚举值出现在 option 路径与取值中，不参与命名风格转换
+	optionTypes := map[*TopDef]struct{}{}
+	var optQueue []defRef
+	for _, filePath := range sortedKeys(selected) {
+		pf := parsed[filePath]
+		for i := range pf.Defs {
+			if _, ok := selected[filePath][pf.Defs[i].Name]; ok && isOptionsExtend(&pf.Defs[i]) {
+				optQueue = append(optQueue, defRef{File: filePath, Def: &pf.Defs[i]})
+			}
+		}
+	}
+	for len(optQueue) > 0 {
+		cur := optQueue[0]
+		optQueue = optQueue[1:]
+		if _, ok := optionTypes[cur.Def]; ok {
+			continue
+		}
+		optionTypes[cur.Def] = struct{}{}
+		for _, tok := range collectTypeTokens(cur.Def.Text) {
+			if dr, ok := resolveDef(cur.File, parsed[cur.File].Package, tok); ok {
+				optQueue = append(optQueue, dr)
+			}
+		}
 	}
 
 	tempRoot := filepath.FromSlash(outDir)
@@ -208,36 +387,197 @@ func (Pruner) BuildPrunedTempProtos(
 		return "", nil, err
 	}
 
+	outName := func(file string) string { return fileCase.apply(trimExt(filepath.Base(file))) + ".proto" }
+	// groupOf 返回定义所在的输出文件（export.grouping）
+	var groupOf func(file string, d *TopDef) string
+	groupOf = func(file string, d *TopDef) string {
+		pkg := parsed[file].Package
+		switch t.Grouping {
+		case "package":
+			return packageFileName(t.rewritePackage(pkg), fileCase)
+		case "definition":
+			if d.Kind == "extend" {
+				// extend 随被扩展的类型输出；扩展外部类型（如 descriptor 中的 *Options）时留在源文件对应的输出中
+				if dr, ok := resolveDef(file, pkg, extendTarget(d)); ok && dr.Def.Kind != "extend" {
+					if _, sel := selected[dr.File][dr.Def.Name]; sel {
+						return groupOf(dr.File, dr.Def)
+					}
+				}
+				return outName(file)
+			}
+			// 按输出 package 分目录，不同 package 的同名定义不会落入同一文件
+			name := fileCase.apply(t.outTypePath(pkg, d.Name, d.Name)) + ".proto"
+			if out := t.rewritePackage(pkg); out != "" {
+				name = strings.ReplaceAll(out, ".", "/") + "/" + name
+			}
+			return name
+		case "bundle":
+			return fileCase.apply(trimExt(t.BundleName)) + ".proto"
+		}
+		return outName(file)
+	}
+
+	// 输出文件：同一文件中的定义须属于同一输出 package，且 syntax/edition 一致
+	type outFile struct {
+		Src  string // 提供 syntax、edition 与文件级 option 的首个源文件
+		Pkg  string
+		Defs []defRef
+	}
+	groups := map[string]*outFile{}
+	for _, filePath := range sortedKeys(parsed) {
+		pf := parsed[filePath]
+		outPkg := t.rewritePackage(pf.Package)
+		if t.Grouping == "source" {
+			// 不同目录的同名文件，或 fileNameCase 转换后同名，会互相覆盖
+			rel := outName(filePath)
+			if g, ok := groups[rel]; ok {
+				return "", nil, fmt.Errorf("输出文件名冲突: %s 与 %s 都映射为 %s", g.Src, filePath, rel)
+			}
+			groups[rel] = &outFile{Src: filePath, Pkg: outPkg}
+		}
+		for i := range pf.Defs {
+			d := &pf.Defs[i]
+			if _, ok := selected[filePath][d.Name]; !ok {
+				continue
+			}
+			rel := groupOf(filePath, d)
+			g := groups[rel]
+			if g == nil {
+				g = &outFile{Src: filePath, Pkg: outPkg}
+				groups[rel] = g
+			}
+			if g.Pkg != outPkg {
+				return "", nil, fmt.Errorf("输出文件 %s 混合了 package %q 与 %q，可配合 flattenPackage 合并", rel, g.Pkg, outPkg)
+			}
+			if src := parsed[g.Src]; src.Syntax != pf.Syntax || src.Edition != pf.Edition {
+				return "", nil, fmt.Errorf("输出文件 %s 混合了 syntax/edition 不同的 %s 与 %s", rel, g.Src, filePath)
+			}
+			g.Defs = append(g.Defs, defRef{File: filePath, Def: d})
+		}
+	}
+	facade := ""
+	if t.Facade != "" {
+		facade = strings.TrimSuffix(t.Facade, ".proto") + ".proto"
+		if g, ok := groups[facade]; ok {
+			return "", nil, fmt.Errorf("输出文件名冲突: facade 与 %s 都映射为 %s", g.Src, facade)
+		}
+	}
+
+	// package 重写、重命名与 typeNameCase 之后，同一目标 package 中的同名定义会冲突
+	owner := map[string]string{}
+	for _, filePath := range sortedKeys(selected) {
+		pf := parsed[filePath]
+		for _, name := range sortedKeys(selected[filePath]) {
+			if strings.HasPrefix(name, "extend ") {
+				continue
+			}
+			fqn := qualify(t.rewritePackage(pf.Package), "", t.outTypePath(pf.Package, name, name))
+			origin := qualify(pf.Package, "", name) + " (" + filePath + ")"
+			if prev, ok := owner[fqn]; ok {
+				return "", nil, fmt.Errorf("定义名冲突: %s 与 %s 都映射为 %s", prev, origin, fqn)
+			}
+			owner[fqn] = origin
+		}
+	}
+	rewriteRefs := func(def, curFile string) string {
+		if !t.rewritesPackages() && len(t.Rename.Types) == 0 && t.TypeNameCase == "keep" {
+			return def
+		}
+		curPkg := parsed[curFile].Package
+		self := t.rewritePackage(curPkg)
+		return rewriteTypeRefs(def, func(tok string, option bool) string {
+			bare := strings.TrimPrefix(tok, ".")
+			if dr, ok := resolveDef(curFile, curPkg, tok); ok && !option {
+				pkg := parsed[dr.File].Package
+				rest := bare
+				if pkg != "" && strings.HasPrefix(rest, pkg+".") {
+					rest = rest[len(pkg)+1:]
+				}
+				out := t.outTypePath(pkg, dr.Def.Name, rest)
+				if out == rest && !t.rewritesPackages() {
+					return tok
+				}
+				return qualify(t.rewritePackage(pkg), self, out)
+			}
+			if _, ok := scalar[bare]; ok {
+				return tok
+			}
+			if _, ok := wellKnown[bare]; ok || strings.HasPrefix(bare, "google.protobuf.") {
+				return tok
+			}
+			// 未解析的引用（如自定义 option 名、相对引用的嵌套类型）按已知 package 前缀改写
+			if pkg, rest, ok := splitKnownPackage(tok, pkgs); ok {
+				if !option {
+					rest = t.outTypePath(pkg, "", rest)
+				}
+				q := qualify(t.rewritePackage(pkg), self, rest)
+				if strings.HasPrefix(tok, ".") && q != rest {
+					q = "." + q
+				}
+				return q
+			}
+			if !option {
+				if out := t.outTypePath(curPkg, "", bare); out != bare {
+					return strings.TrimSuffix(tok, bare) + out
+				}
+			}
+			return tok
+		})
+	}
+
+	// 源文件中的 import public/weak 保留其语义，指向对应的输出文件；其他分组方式按引用重新计算 import
+	keptImports := func(file string) ([]string, map[string]struct{}) {
+		var lines []string
+		outs := map[string]struct{}{}
+		if t.Grouping != "source" {
+			return nil, outs
+		}
+		for _, imp := range parsed[file].Imports {
+			if imp.Kind == "" {
+				continue
+			}
+			if key, ok := importedFile(file, imp.Path); ok {
+				if _, dup := outs[outName(key)]; !dup {
+					outs[outName(key)] = struct{}{}
+					lines = append(lines, "import "+imp.Kind+" \""+outName(key)+"\";\n")
+				}
+			}
+		}
+		return lines, outs
+	}
+
 	var targets []protoItem
-	for filePath, pf := range parsed {
-		base := filepath.Base(filePath)
-		rel := toCase(trimExt(base), caseKind) + ".proto"
+	sources := map[string]string{}
+	enumScopes := map[string]map[string]string{}
+	for _, rel := range sortedKeys(groups) {
+		g := groups[rel]
+		src := parsed[g.Src]
 		dstPath := filepath.Join(tempRoot, rel)
+		outPkg := g.Pkg
 		if dry {
 			fmt.Printf("[dry] mkdir -p %s\n", filepath.Dir(dstPath))
 		} else {
 			_ = os.MkdirAll(filepath.Dir(dstPath), 0o755)
 		}
 
-		chosen := selected[filePath]
-		if len(chosen) == 0 {
+		if len(g.Defs) == 0 {
 			if dry {
 				fmt.Printf("[dry] write stub %s\n", shortPath(dstPath))
 			} else {
 				var b strings.Builder
-				if pf.Syntax != "" {
-					b.WriteString("syntax = \"" + pf.Syntax + "\";\n\n")
-				} else {
-					b.WriteString("syntax = \"proto3\";\n\n")
+				writeFileHeader(&b, src)
+				if outPkg != "" {
+					b.WriteString("package " + outPkg + ";\n\n")
 				}
-				if pf.Package != "" {
-					b.WriteString("package " + pf.Package + ";\n\n")
+				if lines, _ := keptImports(g.Src); len(lines) > 0 {
+					b.WriteString(strings.Join(lines, "") + "\n")
 				}
-				if ns != "" {
-					writeLangNamespaceOption(&b, lang, ns)
+				if opts := append(src.featureOptions(), t.fileOptions(src, trimExt(rel), seedKeepPath(inDir, g.Src))...); len(opts) > 0 {
+					writeFileOptions(&b, opts)
 					b.WriteString("\n\n")
 				}
 				outTxt := sanitizeProtoOutput(b.String())
+				sources[rel] = outTxt
 				if err := os.WriteFile(dstPath, []byte(outTxt), 0o644); err != nil {
 					return "", nil, err
 				}
@@ -249,75 +589,101 @@ func (Pruner) BuildPrunedTempProtos(
 		if dry {
 			fmt.Printf("[dry] write pruned %s\n", shortPath(dstPath))
 		} else {
-			srcData, err := os.ReadFile(filePath)
-			if err != nil {
-				return "", nil, err
-			}
-			for _, d := range pf.Defs {
-				if _, ok := chosen[d.Name]; !ok {
-					continue
+			crossImports := map[string]struct{}{}
+			googleImports := map[string]struct{}{}
+			for _, cur := range g.Defs {
+				filePath, pf, d := cur.File, parsed[cur.File], cur.Def
+				srcData, err := os.ReadFile(filePath)
+				if err != nil {
+					return "", nil, err
 				}
 				def := extractOriginalBlock(srcData, d.Text)
 				if keepSet := resolveTypeKeepSet(typeFieldKeep, pf.Package, d.Name); keepSet != nil && strings.TrimSpace(d.Kind) == "message" {
 					def = pruneMessageFields(def, keepSet)
 				}
-				def = stripSelfPackageQualifiers(def, pf.Package)
-				if strings.TrimSpace(d.Kind) == "message" {
-					def = transformFieldNames(def, fieldNameCase)
-				}
-				prunedDefs = append(prunedDefs, def)
-			}
-			presentBase := map[string]struct{}{}
-			for _, def := range prunedDefs {
+				def = stripOptionUses(def, dropOption(pf.Package))
+				// import 按裁剪后、改写前的引用计算，指向被引用定义所在的输出文件
 				for _, tok := range collectTypeTokens(def) {
-					presentBase[baseName(tok)] = struct{}{}
-				}
-			}
-			crossImports := map[string]struct{}{}
-			googleImports := map[string]struct{}{}
-			for i := range pf.Defs {
-				d := &pf.Defs[i]
-				if _, ok := chosen[d.Name]; !ok {
-					continue
-				}
-				for _, tok := range d.Refs {
 					tokTrim := strings.TrimPrefix(strings.TrimSpace(tok), ".")
-					if _, ok := presentBase[baseName(tokTrim)]; !ok {
-						continue
-					}
 					if imp, ok := wellKnown[tokTrim]; ok {
 						googleImports[imp] = struct{}{}
 						continue
 					}
 					if dr, ok := resolveDef(filePath, pf.Package, tokTrim); ok {
-						if dr.File != filePath {
-							crossImports[dr.File] = struct{}{}
+						if out := groupOf(dr.File, dr.Def); out != rel {
+							crossImports[out] = struct{}{}
+						}
+					}
+				}
+				for _, name := range optionNames(def) {
+					if dr, _, ok := resolveOption(pf.Package, name); ok {
+						if out := groupOf(dr.File, dr.Def); out != rel {
+							crossImports[out] = struct{}{}
 						}
 					}
 				}
+				// proto2 枚举默认值随枚举值的重命名与命名风格改写
+				def = rewriteEnumDefaults(def, func(typ, value string) string {
+					enum, renames := baseName(typ), map[string]string(nil)
+					if dr, ok := resolveDef(filePath, pf.Package, typ); ok && dr.Def.Kind == "enum" {
+						enum, renames = dr.Def.Name, memberRenames(t.Rename.EnumValues, parsed[dr.File].Package, dr.Def.Name)
+					}
+					if nn, ok := renames[value]; ok {
+						return nn
+					}
+					return enumValueName(enum, value, t.style(t.EnumValueCase), t.StripEnumPrefix)
+				})
+				def = rewriteRefs(def, filePath)
+				def = stripSelfPackageQualifiers(def, outPkg)
+				origin := qualify(pf.Package, "", d.Name)
+				_, isOption := optionTypes[d]
+				if kind := strings.TrimSpace(d.Kind); (kind == "message" || kind == "extend") && !isOption {
+					if def, err = transformFieldNames(def, t.style(t.FieldNameCase), t.style(t.TypeNameCase), memberRenames(t.Rename.Fields, pf.Package, d.Name), t.PreserveJSON); err != nil {
+						return "", nil, fmt.Errorf("%s 字段名冲突: %w", origin, err)
+					}
+				}
+				// 顶层枚举的值在所属 package 内共享作用域
+				if enumScopes[outPkg] == nil {
+					enumScopes[outPkg] = map[string]string{}
+				}
+				enumStyle := t.style(t.EnumValueCase)
+				if isOption {
+					enumStyle = caseStyle{}
+				}
+				if def, err = transformEnumValues(def, enumStyle, t.StripEnumPrefix && !isOption, memberRenames(t.Rename.EnumValues, pf.Package, d.Name), enumScopes[outPkg]); err != nil {
+					return "", nil, fmt.Errorf("%s 枚举值冲突: %w", origin, err)
+				}
+				if def, err = transformTypeNames(def, t.style(t.TypeNameCase)); err != nil {
+					return "", nil, fmt.Errorf("%s 嵌套类型名冲突: %w", origin, err)
+				}
+				if name, ok := t.renamedType(pf.Package, d.Name); ok {
+					def = renameDefHeader(def, name)
+				}
+				prunedDefs = append(prunedDefs, def)
 			}
 
 			var b strings.Builder
-			if pf.Syntax != "" {
-				b.WriteString("syntax = \"" + pf.Syntax + "\";\n\n")
-			} else {
-				b.WriteString("syntax = \"proto3\";\n\n")
+			writeFileHeader(&b, src)
+			if outPkg != "" {
+				b.WriteString("package " + outPkg + ";\n\n")
 			}
-			if pf.Package != "" {
-				b.WriteString("package " + pf.Package + ";\n\n")
+			kept, keptOuts := keptImports(g.Src)
+			for _, line := range kept {
+				b.WriteString(line)
 			}
-			for imp := range crossImports {
-				impName := toCase(trimExt(filepath.Base(imp)), caseKind) + ".proto"
-				b.WriteString("import \"" + impName + "\";\n")
+			for _, imp := range sortedKeys(crossImports) {
+				if _, ok := keptOuts[imp]; !ok {
+					b.WriteString("import \"" + imp + "\";\n")
+				}
 			}
-			for imp := range googleImports {
+			for _, imp := range sortedKeys(googleImports) {
 				b.WriteString("import \"" + imp + "\";\n")
 			}
-			if len(crossImports) > 0 || len(googleImports) > 0 {
+			if len(kept) > 0 || len(crossImports) > 0 || len(googleImports) > 0 {
 				b.WriteString("\n")
 			}
-			if ns != "" {
-				writeLangNamespaceOption(&b, lang, ns)
+			if opts := append(src.featureOptions(), t.fileOptions(src, trimExt(rel), seedKeepPath(inDir, g.Src))...); len(opts) > 0 {
+				writeFileOptions(&b, opts)
 				b.WriteString("\n\n")
 			}
 			for _, def := range prunedDefs {
@@ -325,6 +691,7 @@ func (Pruner) BuildPrunedTempProtos(
 				b.WriteString("\n\n")
 			}
 			outTxt := sanitizeProtoOutput(b.String())
+			sources[rel] = outTxt
 			if err := os.WriteFile(dstPath, []byte(outTxt), 0o644); err != nil {
 				return "", nil, err
 			}
@@ -332,27 +699,58 @@ func (Pruner) BuildPrunedTempProtos(
 		targets = append(targets, protoItem{Path: rel, Dir: "", Base: rel})
 	}
 
+	if facade != "" {
+		txt := facadeText(sortedKeys(groups))
+		if dry {
+			fmt.Printf("[dry] write facade %s\n", shortPath(filepath.Join(tempRoot, facade)))
+		} else if err := os.WriteFile(filepath.Join(tempRoot, facade), []byte(txt), 0o644); err != nil {
+			return "", nil, err
+		}
+		sources[facade] = txt
+		targets = append(targets, protoItem{Path: facade, Base: facade})
+	}
+	if t.DescriptorSet != "" {
+		if err := writeDescriptorSet(t, sources, dry); err != nil {
+			return "", nil, fmt.Errorf("生成描述符集失败: %w", err)
+		}
+	}
+	if t.JSONSchema != "" {
+		if err := writeJSONSchemas(t, sources, dry); err != nil {
+			return "", nil, fmt.Errorf("生成 JSON Schema 失败: %w", err)
+		}
+	}
+
 	return tempRoot, targets, nil
 }
 
-func writeLangNamespaceOption(b *strings.Builder, lang, ns string) {
-	switch strings.ToLower(strings.TrimSpace(lang)) {
-	case "csharp", "cs", "c#":
-		b.WriteString("option csharp_namespace = \"" + ns + "\";")
-	case "golang", "go":
-		b.WriteString("option go_package = \"" + ns + "\";")
-	case "lua":
+// packageFileName names the output file of a proto package under grouping: package.
+func packageFileName(pkg string, fileCase caseStyle) string {
+	if pkg == "" {
+		pkg = "default"
+	}
+	return fileCase.apply(strings.ReplaceAll(pkg, ".", "_")) + ".proto"
+}
 
-	default:
-		b.WriteString("option csharp_namespace = \"" + ns + "\";")
+// facadeText is a file that re-exports every output through `import public`.
+func facadeText(outputs []string) string {
+	var b strings.Builder
+	b.WriteString("syntax = \"proto3\";\n\n")
+	for _, out := range outputs {
+		b.WriteString("import public \"" + out + "\";\n")
 	}
+	return b.String()
+}
+
+// seedKeepPath returns the key under which seedKeep stores the keep set of a seed file.
+func seedKeepPath(inDir, filePath string) string {
+	keepPath, _ := filepath.Rel(inDir, filePath)
+	return filepath.ToSlash(keepPath)
 }
 
 func sanitizeProtoOutput(s string) string {
 	noCmt := stripCommentsOut(s)
-	noRes := dropReservedLines(noCmt)
 	// 先全局归一化一次空行
-	compact := normalizeBlankLines(noRes)
+	compact := normalizeBlankLines(noCmt)
 	// 消除块内（message/enum）字段间空行
 	compact = dropBlankLinesInsideTopBlocks(compact)
 	// 修复花括号附近空行
@@ -414,20 +812,6 @@ func stripCommentsOut(s string) string {
 	return out.String()
 }
 
-var reservedLineRe = regexp.MustCompile(`(?i)^\s*reserved\b[^;]*;\s*$`)
-
-func dropReservedLines(s string) string {
-	lines := strings.Split(s, "\n")
-	out := make([]string, 0, len(lines))
-	for _, ln := range lines {
-		if reservedLineRe.MatchString(ln) {
-			continue
-		}
-		out = append(out, ln)
-	}
-	return strings.Join(out, "\n")
-}
-
 func normalizeBlankLines(s string) string {
 	lines := strings.Split(s, "\n")
 	out := make([]string, 0, len(lines))
@@ -544,6 +928,10 @@ func pruneMessageFields(def string, keepSet map[string]struct{}) string {
 				continue
 			}
 			if kw == "message" || kw == "enum" || kw == "extend" {
+				// 跳过类型名（extend 的目标可带 package 限定）到左花括号
+				for start < n && body[start] != '{' && body[start] != ';' {
+					start++
+				}
 				_, blkEnd := findBlock(body, start)
 				if blkEnd <= start {
 					out.WriteString(body[cur:])
@@ -582,6 +970,10 @@ func pruneMessageFields(def string, keepSet map[string]struct{}) string {
 					depth--
 				}
 				cur++
+				// proto2 group 字段以 } 结束
+				if depth == 0 && reGroupField.MatchString(strings.TrimSpace(stripComments(body[stmtStart:cur]))) {
+					break
+				}
 				continue
 			}
 			if body[cur] == ';' && depth == 0 {
@@ -630,7 +1022,7 @@ var (
 	reLineComment  = regexp.MustCompile(`(?m)//.*$`)
 	reBlockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
 	reMapType      = regexp.MustCompile(`map\s*<\s*([A-Za-z_][\w\.]*)\s*,\s*([A-Za-z_][\w\.]*)\s*>`)
-	reFieldType    = regexp.MustCompile(`(?m)(?:^|[\s{])(?:repeated|optional)?\s*([A-Za-z_][\w\.]*)\s+[A-Za-z_][\w]*\s*=\s*\d+\s*;`)
+	reFieldType    = regexp.MustCompile(`(?m)(?:^|[\s{;])(?:repeated|optional|required)?\s*(\.?[A-Za-z_][\w\.]*)\s+[A-Za-z_][\w]*\s*=\s*\d+\s*[;\[]`)
 )
 
 func collectTypeTokens(def string) []string {
@@ -648,6 +1040,13 @@ func collectTypeTokens(def string) []string {
 			toks[m[1]] = struct{}{}
 		}
 	}
+	for _, m := range reRefRPC.FindAllStringSubmatch(s, -1) {
+		toks[m[1]] = struct{}{}
+		toks[m[2]] = struct{}{}
+	}
+	for _, m := range reRefExtend.FindAllStringSubmatch(s, -1) {
+		toks[m[1]] = struct{}{}
+	}
 	out := make([]string, 0, len(toks))
 	for t := range toks {
 		out = append(out, t)
@@ -750,11 +1149,28 @@ func findBlock(s string, i int) (start, end int) {
 	return start, end
 }
 
+var reLegacyRequired = regexp.MustCompile(`features\.field_presence\s*=\s*LEGACY_REQUIRED`)
+
+var reGroupField = regexp.MustCompile(`^(?:optional|required|repeated)\s+group\s+([A-Za-z_]\w*)\s*=`)
+
 func keepFieldStmt(stmt string, keepSet map[string]struct{}) bool {
 	s := strings.TrimSpace(stmt)
 	if s == "" {
 		return true
 	}
+	// required 字段（editions 中为 LEGACY_REQUIRED）缺失会导致对端解析失败，裁剪时始终保留
+	if strings.HasPrefix(strings.TrimSpace(stripComments(s)), "required ") || reLegacyRequired.MatchString(s) {
+		return true
+	}
+	// group 的字段名为组名的小写形式，两者均可写在 keep 中
+	if m := reGroupField.FindStringSubmatch(strings.TrimSpace(stripComments(s))); m != nil {
+		if len(keepSet) == 0 {
+			return true
+		}
+		_, ok := keepSet[strings.ToLower(m[1])]
+		_, ok2 := keepSet[m[1]]
+		return ok || ok2
+	}
 	if strings.HasPrefix(s, "oneof ") || strings.HasPrefix(s, "message ") || strings.HasPrefix(s, "enum ") || strings.HasPrefix(s, "extend ") {
 		return true
 	}
@@ -845,22 +1261,111 @@ func parseProtoFile(path string) (*PFile, error) {
 	}
 	content := string(data)
 	noCom := stripComments(content)
-	syn := "proto3"
+	syn, edition := "proto3", ""
 	if m := regexp.MustCompile(`(?m)^\s*syntax\s*=\s*"([^"]+)"\s*;`).FindStringSubmatch(noCom); len(m) == 2 {
 		syn = m[1]
 	}
+	if m := regexp.MustCompile(`(?m)^\s*edition\s*=\s*"([^"]+)"\s*;`).FindStringSubmatch(noCom); len(m) == 2 {
+		syn, edition = "editions", m[1]
+	}
 	pkg := ""
 	if m := regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z_][\w\.]*?)\s*;`).FindStringSubmatch(noCom); len(m) == 2 {
 		pkg = m[1]
 	}
 	blocks := scanTopLevelBlocks(content)
 	defs := make([]TopDef, 0, len(blocks))
+	extends := map[string]int{}
 	for _, bl := range blocks {
 		body := blockBody(content, bl)
 		refs := extractTypeRefs(stripComments(body))
-		defs = append(defs, TopDef{Kind: bl.kind, Name: bl.name, Text: bl.fullText(content), Refs: refs})
+		name := bl.name
+		if bl.kind == "extend" {
+			// 顶层 extend 没有自己的名字：以被扩展类型命名，同一类型的多个 extend 依次编号
+			name = extendDefName(bl.name, extends[bl.name])
+			extends[bl.name]++
+			refs = append(refs, bl.name)
+		}
+		defs = append(defs, TopDef{Kind: bl.kind, Name: name, Text: bl.fullText(content), Refs: refs})
 	}
-	return &PFile{Path: filepath.ToSlash(path), Package: pkg, Syntax: syn, Defs: defs}, nil
+	return &PFile{Path: filepath.ToSlash(path), Package: pkg, Syntax: syn, Edition: edition, Options: topLevelOptions(noCom), Imports: parseImports(noCom), Defs: defs}, nil
+}
+
+// topLevelOptions parses the file-level `option name = value;` statements of comment-free src.
+func topLevelOptions(src string) []fileOption {
+	var out []fileOption
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '"' || c == '\'':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case depth == 0 && isIdentStart(c) && (i == 0 || !isIdent(src[i-1])):
+			kw, next := readKeyword(src, i)
+			if kw != "option" {
+				i = next - 1
+				continue
+			}
+			end := scanTo(src, next, ';')
+			if end < 0 {
+				return out
+			}
+			if parts := splitTopLevel(src[next:end], '='); len(parts) >= 2 {
+				out = append(out, fileOption{Name: strings.Join(strings.Fields(parts[0]), ""), Value: strings.TrimSpace(strings.Join(parts[1:], "="))})
+			}
+			i = end
+		}
+	}
+	return out
+}
+
+// featureOptions returns the file-level features.* options of an editions file.
+func (pf *PFile) featureOptions() []fileOption {
+	if pf.Edition == "" {
+		return nil
+	}
+	var out []fileOption
+	for _, o := range pf.Options {
+		if strings.HasPrefix(o.Name, "features.") {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// writeFileHeader writes the syntax or edition line of an output file.
+func writeFileHeader(b *strings.Builder, pf *PFile) {
+	switch {
+	case pf.Edition != "":
+		b.WriteString("edition = \"" + pf.Edition + "\";\n\n")
+	case pf.Syntax != "":
+		b.WriteString("syntax = \"" + pf.Syntax + "\";\n\n")
+	default:
+		b.WriteString("syntax = \"proto3\";\n\n")
+	}
+}
+
+// extendDefName keys the i-th top-level extend of target within one file.
+func extendDefName(target string, i int) string {
+	if i == 0 {
+		return "extend " + target
+	}
+	return fmt.Sprintf("extend %s#%d", target, i+1)
+}
+
+// extendTarget returns the extended type of a top-level extend definition.
+func extendTarget(d *TopDef) string {
+	name := strings.TrimPrefix(d.Name, "extend ")
+	if i := strings.Index(name, "#"); i >= 0 {
+		name = name[:i]
+	}
+	return name
 }
 
 type block struct {
@@ -930,12 +1435,13 @@ func scanTopLevelBlocks(src string) []block {
 				i++
 			}
 			kw := src[start:i]
-			if kw == "message" || kw == "enum" {
+			if kw == "message" || kw == "enum" || kw == "service" || kw == "extend" {
 				for i < n && isSpace(src[i]) {
 					i++
 				}
 				nameStart := i
-				for i < n && isIdent(src[i]) {
+				// extend 的名称为被扩展的类型，可带 package 限定
+				for i < n && (isIdent(src[i]) || (kw == "extend" && src[i] == '.')) {
 					i++
 				}
 				name := src[nameStart:i]
@@ -1039,8 +1545,49 @@ func stripComments(s string) string {
 	return b.String()
 }
 
+// splitStatements cuts a line after every ';', '{' and '}' outside comments, strings and option
+// brackets, so that several statements on one line are matched one by one; joining the parts
+// gives back the line.
+func splitStatements(ln string) []string {
+	var parts []string
+	start, depth := 0, 0
+	var quote byte
+	for i := 0; i < len(ln); i++ {
+		c := ln[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/' && i+1 < len(ln) && ln[i+1] == '/':
+			i = len(ln)
+		case c == '/' && i+1 < len(ln) && ln[i+1] == '*':
+			if end := strings.Index(ln[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(ln)
+			}
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && (c == ';' || c == '{' || c == '}'):
+			parts = append(parts, ln[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(ln) || len(parts) == 0 {
+		parts = append(parts, ln[start:])
+	}
+	return parts
+}
+
 func extractTypeRefs(s string) []string {
-	re := regexp.MustCompile(`(?m)^\s*(?:repeated|optional)?\s*([^\s=]+(?:\s*<[^;>]+>)?)\s+[A-Za-z_][\w]*\s*=\s*\d+`)
+	re := regexp.MustCompile(`(?m)^\s*(?:repeated|optional|required)?\s*([^\s=]+(?:\s*<[^;>]+>)?)\s+[A-Za-z_][\w]*\s*=\s*\d+`)
 	m := re.FindAllStringSubmatch(s, -1)
 	var out []string
 	for _, g := range m {
@@ -1063,6 +1610,9 @@ func extractTypeRefs(s string) []string {
 		}
 		out = append(out, typ)
 	}
+	for _, m := range reRefRPC.FindAllStringSubmatch(s, -1) {
+		out = append(out, m[1], m[2])
+	}
 	return out
 }
 
@@ -1076,26 +1626,115 @@ func stripSelfPackageQualifiers(content string, selfPkg string) string {
 	return re.ReplaceAllString(content, `$1`)
 }
 
-func transformFieldNames(def string, caseKind string) string {
+var (
+	// reservedNamesRe 匹配保留字段名的语句：proto2/proto3 用字符串，editions 用标识符
+	reservedNamesRe = regexp.MustCompile(`^\s*reserved\s+(?:"|'|[A-Za-z_])`)
+	reservedNameRe  = regexp.MustCompile(`"[A-Za-z_]\w*"|'[A-Za-z_]\w*'|\b[A-Za-z_]\w*\b`)
+)
+
+// transformFieldNames applies style to the field names of a message; renames (old -> new)
+// take precedence for the message's own fields, including oneof members. With preserveJSON a
+// renamed field gets json_name set to its original JSON name. A field that ends up with the same
+// name as another field, a nested type (named by typeStyle) or a oneof of the same message is
+// reported as an error. Sharing the name of a type from an outer scope is fine: protoc skips
+// fields when it resolves a type name.
+func transformFieldNames(def string, style, typeStyle caseStyle, renames map[string]string, preserveJSON bool) (string, error) {
 	// keep：保持字段名不变
-	if strings.ToLower(strings.TrimSpace(caseKind)) == "keep" {
-		return def
+	if style.keep() && typeStyle.keep() && len(renames) == 0 {
+		return def, nil
 	}
 	i := strings.Index(def, "{")
 	j := strings.LastIndex(def, "}")
 	if i < 0 || j <= i {
-		return def
+		return def, nil
 	}
 	head := def[:i+1]
 	body := def[i+1 : j]
 	tail := def[j:]
 
 	lines := strings.Split(body, "\n")
-	fieldRe := regexp.MustCompile(`^([\t ]*(?:repeated[\t ]+)?(?:map\s*<[^>]+>|[^\s=]+)[\t ]+)([A-Za-z_][\w]*)([\t ]*=\s*\d+.*;.*)$`)
-	for idx, ln := range lines {
-		if m := fieldRe.FindStringSubmatch(ln); m != nil {
-			lines[idx] = m[1] + toCase(m[2], caseKind) + m[3]
+	fieldRe := regexp.MustCompile(`^([\t ]*(?:(?:repeated|optional|required)[\t ]+)?(?:map\s*<[^>]+>|[^\s=]+)[\t ]+)([A-Za-z_][\w]*)([\t ]*=\s*\d+.*;.*)$`)
+	// blocks 记录当前所处的块，首项为 message 本身；oneof 不构成作用域，其字段归属外层 message
+	type block struct {
+		oneof bool
+		names map[string]string // 输出名 -> 原名（嵌套类型与 oneof 带上种类）
+	}
+	blocks := []block{{names: map[string]string{}}}
+	// declare 在 oneof 之外最近的 message 作用域中登记名称，与已登记的其它项同名时报错
+	declare := func(name, orig string) error {
+		scope := len(blocks) - 1
+		for scope > 0 && blocks[scope].oneof {
+			scope--
+		}
+		if prev, ok := blocks[scope].names[name]; ok && prev != orig {
+			return fmt.Errorf("%s 与 %s 都映射为 %s", prev, orig, name)
+		}
+		blocks[scope].names[name] = orig
+		return nil
+	}
+	for idx, line := range lines {
+		parts := splitStatements(line)
+		for k, ln := range parts {
+			if loc := reDefHeader.FindStringSubmatchIndex(ln); loc != nil {
+				orig := ln[loc[4]:loc[5]]
+				if err := declare(typeStyle.apply(orig), strings.TrimSpace(ln[loc[2]:loc[3]])+" "+orig); err != nil {
+					return def, err
+				}
+			} else if reOneofLine.MatchString(ln) {
+				if f := strings.Fields(ln); len(f) > 1 {
+					orig := strings.TrimSuffix(f[1], "{")
+					if err := declare(orig, "oneof "+orig); err != nil {
+						return def, err
+					}
+				}
+			}
+			if reservedNamesRe.MatchString(ln) {
+				// 保留的字段名与字段同样改名，保证其仍指向输出中的名称
+				top := len(blocks) == 1
+				kw := strings.Index(ln, "reserved") + len("reserved")
+				parts[k] = ln[:kw] + reservedNameRe.ReplaceAllStringFunc(ln[kw:], func(q string) string {
+					quote := ""
+					if strings.HasPrefix(q, `"`) || strings.HasPrefix(q, "'") {
+						quote = q[:1]
+					}
+					old := strings.Trim(q, `"'`)
+					name := style.apply(old)
+					if nn, ok := renames[old]; ok && top {
+						name = nn
+					}
+					return quote + name + quote
+				})
+			}
+			if m := fieldRe.FindStringSubmatch(ln); m != nil {
+				scope := len(blocks) - 1
+				for scope > 0 && blocks[scope].oneof {
+					scope--
+				}
+				name := style.apply(m[2])
+				if nn, ok := renames[m[2]]; ok && scope == 0 {
+					name = nn
+				}
+				if err := declare(name, "字段 "+m[2]); err != nil {
+					return def, err
+				}
+				rest := m[3]
+				if preserveJSON && name != m[2] {
+					rest = withJSONName(rest, jsonName(m[2]))
+				}
+				parts[k] = m[1] + name + rest
+			}
+			for _, c := range stripComments(ln) {
+				switch c {
+				case '{':
+					blocks = append(blocks, block{oneof: reOneofLine.MatchString(ln), names: map[string]string{}})
+				case '}':
+					if len(blocks) > 1 {
+						blocks = blocks[:len(blocks)-1]
+					}
+				}
+			}
 		}
+		lines[idx] = strings.Join(parts, "")
 	}
-	return head + strings.Join(lines, "\n") + tail
+	return head + strings.Join(lines, "\n") + tail, nil
 }
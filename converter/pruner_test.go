@@ -0,0 +1,259 @@
+package converter
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPruneMessageFieldsProto2(t *testing.T) {
+	def := `message Search {
+  required string query = 1;
+  optional int32 page = 2 [default = 1];
+  repeated group Result = 3 {
+    optional string url = 4;
+  }
+  optional string dropped = 5 [(opt) = { a: 1 }];
+  message Detail { int32 v = 1; }
+  extensions 100 to max;
+}`
+	want := `message Search {
+  required string query = 1;
+  repeated group Result = 3 {
+    optional string url = 4;
+  }
+  message Detail { int32 v = 1; }
+  extensions 100 to max;
+}`
+	if got := pruneMessageFields(def, map[string]struct{}{"result": {}}); got != want {
+		t.Errorf("pruneMessageFields =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCollectTypeTokens(t *testing.T) {
+	def := `extend .game.Search {
+  optional Color color = 100 [default = RED];
+  required .shared.Pair pair = 101;
+  map<string, Detail> details = 102;
+}`
+	got := collectTypeTokens(def)
+	sort.Strings(got)
+	want := []string{".game.Search", ".shared.Pair", "Color", "Detail", "string"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectTypeTokens = %v, want %v", got, want)
+	}
+}
+
+func TestRewriteEnumDefaults(t *testing.T) {
+	def := "message M {\n  optional Color c = 1 [default = COLOR_RED];\n  optional bool b = 2 [default = true];\n}"
+	want := "message M {\n  optional Color c = 1 [default = Red];\n  optional bool b = 2 [default = true];\n}"
+	got := rewriteEnumDefaults(def, func(typ, value string) string {
+		return enumValueName(typ, value, caseStyle{Kind: "camel"}, true)
+	})
+	if got != want {
+		t.Errorf("rewriteEnumDefaults = %q, want %q", got, want)
+	}
+}
+
+func TestTopLevelOptions(t *testing.T) {
+	src := `edition = "2023";
+package ed;
+option features.field_presence = IMPLICIT;
+option (my.opt) = { a: "x;y" };
+message M {
+  option deprecated = true;
+}
+option java_package = "com.example";`
+	want := []fileOption{
+		{Name: "features.field_presence", Value: "IMPLICIT"},
+		{Name: "(my.opt)", Value: `{ a: "x;y" }`},
+		{Name: "java_package", Value: `"com.example"`},
+	}
+	if got := topLevelOptions(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("topLevelOptions = %v, want %v", got, want)
+	}
+}
+
+func TestParseImports(t *testing.T) {
+	src := `syntax = "proto3";
+import "a.proto";
+import public "pub/b.proto";
+  import weak  "c.proto" ;
+message M { string important = 1; }`
+	want := []protoImport{
+		{Path: "a.proto"},
+		{Path: "pub/b.proto", Kind: "public"},
+		{Path: "c.proto", Kind: "weak"},
+	}
+	if got := parseImports(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseImports = %v, want %v", got, want)
+	}
+}
+
+func TestPackageFileName(t *testing.T) {
+	tests := []struct {
+		pkg  string
+		kind string
+		out  string
+	}{
+		{"game.shared", "keep", "game_shared.proto"},
+		{"game.shared", "camel", "GameShared.proto"},
+		{"", "keep", "default.proto"},
+	}
+	for _, tt := range tests {
+		if got := packageFileName(tt.pkg, caseStyle{Kind: tt.kind}); got != tt.out {
+			t.Errorf("packageFileName(%q, %s) = %q, want %q", tt.pkg, tt.kind, got, tt.out)
+		}
+	}
+}
+
+// exportImports runs the export at cfg and returns the imports of each output file under out.
+func exportImports(t *testing.T, cfg string) map[string][]string {
+	t.Helper()
+	if err := os.RemoveAll("out"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("cfg.yaml", []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Exporter{ConfigPath: "cfg.yaml"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	importRe := regexp.MustCompile(`(?m)^import (?:public )?"([^"]+)";`)
+	got := map[string][]string{}
+	err := filepath.WalkDir("out", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel("out", path)
+		imports := []string{}
+		for _, m := range importRe.FindAllStringSubmatch(string(data), -1) {
+			imports = append(imports, m[1])
+		}
+		got[filepath.ToSlash(rel)] = imports
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestGroupingImports(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/a/a.proto": `syntax = "proto3";
+package game.a;
+message Item { int32 id = 1; }
+enum Color { COLOR_NONE = 0; }
+`,
+		"proto/b/b.proto": `syntax = "proto3";
+package game.b;
+import "a/a.proto";
+message Item { game.a.Color color = 1; }
+`,
+		"proto/c/bag.proto": `syntax = "proto3";
+package game.c;
+import "a/a.proto";
+import "b/b.proto";
+message Bag {
+  game.a.Item a = 1;
+  game.b.Item b = 2;
+}
+`,
+	})
+	const head = "import:\n  dir: proto\n  keep:\n    files:\n"
+	tests := []struct {
+		name string
+		cfg  string
+		want map[string][]string
+	}{
+		{"package", head + "      - file: c/bag\nexport:\n  language: csharp\n  dir: out\n  grouping: package\n", map[string][]string{
+			"game_a.proto": {},
+			"game_b.proto": {"game_a.proto"},
+			"game_c.proto": {"game_a.proto", "game_b.proto"},
+		}},
+		{"definition", head + "      - file: c/bag\nexport:\n  language: csharp\n  dir: out\n  grouping: definition\n", map[string][]string{
+			"game/a/Item.proto":  {},
+			"game/a/Color.proto": {},
+			"game/b/Item.proto":  {"game/a/Color.proto"},
+			"game/c/Bag.proto":   {"game/a/Item.proto", "game/b/Item.proto"},
+		}},
+		{"bundle", head + "      - file: b/b\nexport:\n  language: csharp\n  dir: out\n  grouping: bundle\n  flattenPackage: pb\n", map[string][]string{
+			"bundle.proto": {},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exportImports(t, tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imports = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicImportChain(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		// coin.proto 没有 package，其类型只能经 import public 链对 app.proto 可见
+		"proto/base/coin.proto": "syntax = \"proto3\";\nmessage Coin { int64 amount = 1; }\n",
+		// 相对导入方所在目录的 import
+		"proto/base/gem.proto":     "syntax = \"proto3\";\npackage base;\nimport public \"coin.proto\";\nmessage Gem { int32 id = 1; }\n",
+		"proto/mid/reexport.proto": "syntax = \"proto3\";\npackage mid;\nimport public \"base/gem.proto\";\n",
+		"proto/app/app.proto":      "syntax = \"proto3\";\npackage app;\nimport \"mid/reexport.proto\";\nmessage Wallet {\n  Coin coin = 1;\n  base.Gem gem = 2;\n}\n",
+	})
+	got := exportImports(t, "import:\n  dir: proto\n  keep:\n    files:\n      - file: app/app\nexport:\n  language: csharp\n  dir: out\n  facade: all\n")
+	want := map[string][]string{
+		"all.proto":      {"app.proto", "coin.proto", "gem.proto", "reexport.proto"},
+		"app.proto":      {"coin.proto", "gem.proto"},
+		"coin.proto":     {},
+		"gem.proto":      {"coin.proto"},
+		"reexport.proto": {"gem.proto"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imports = %v, want %v", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join("out", "all.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if facade := "syntax = \"proto3\";\n\nimport public \"app.proto\";\nimport public \"coin.proto\";\nimport public \"gem.proto\";\nimport public \"reexport.proto\";\n"; string(data) != facade {
+		t.Errorf("facade =\n%s\nwant\n%s", data, facade)
+	}
+}
+
+func TestFieldNamedAfterItsType(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/item.proto": "syntax = \"proto3\";\npackage game;\nenum Kind { KIND_A = 0; }\nmessage Item { Kind kind = 1; }\n",
+		"proto/slot.proto": "syntax = \"proto3\";\npackage game;\nmessage Slot { enum Kind { SLOT_A = 0; } Kind kind = 1; }\n",
+	})
+	cfg := "import:\n  dir: proto\n  keep:\n    files:\n      - file: %s\nexport:\n  dir: out\n  language: csharp\n  fieldNameCase: camel\n  descriptorSet: out/set.pb\n"
+	tests := []struct {
+		seed, wantErr string
+	}{
+		// 与外层类型同名可以：protoc 查找类型时跳过同名字段
+		{"item", ""},
+		// 与同一 message 内的嵌套类型同名则冲突
+		{"slot", "enum Kind 与 字段 kind 都映射为 Kind"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile("cfg.yaml", []byte(fmt.Sprintf(cfg, tt.seed)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := (&Exporter{ConfigPath: "cfg.yaml"}).Run()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: %v", tt.seed, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", tt.seed, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RenameRules renames definitions in the output. Owner names may be short (Player) or
+// package-qualified (game.Player); only top-level definitions are addressed.
+type RenameRules struct {
+	// Types renames messages, enums and services, e.g. PlayerInfoDataV2Final: PlayerInfo.
+	Types map[string]string `yaml:"types"`
+	// Fields renames message fields as "Message.field: newName"; field numbers are kept.
+	Fields map[string]string `yaml:"fields"`
+	// EnumValues renames enum values as "Enum.VALUE: NEW_VALUE"; numbers are kept.
+	EnumValues map[string]string `yaml:"enumValues"`
+}
+
+var (
+	reOneofLine   = regexp.MustCompile(`^\s*oneof\s`)
+	reDefHeader   = regexp.MustCompile(`(?m)^(\s*(?:message|enum|service)\s+)([A-Za-z_]\w*)`)
+	reEnumHeader  = regexp.MustCompile(`^\s*enum\s+([A-Za-z_]\w*)`)
+	reEnumDefault = regexp.MustCompile(`(?m)((?:^|[\s{;])(?:(?:repeated|optional|required)\s+)?(\.?[A-Za-z_][\w\.]*)\s+[A-Za-z_]\w*\s*=\s*\d+\s*\[[^\]]*?\bdefault\s*=\s*)([A-Za-z_]\w*)`)
+	reEnumValue   = regexp.MustCompile(`(?m)^([\t ]*)([A-Za-z_]\w*)([\t ]*=[\t ]*-?(?:0[xX][0-9A-Fa-f]+|\d+))`)
+)
+
+// renamedType returns the output name of the top-level definition name in package pkg.
+func (t exportTarget) renamedType(pkg, name string) (string, bool) {
+	if pkg != "" {
+		if v, ok := t.Rename.Types[pkg+"."+name]; ok {
+			return v, true
+		}
+	}
+	v, ok := t.Rename.Types[name]
+	return v, ok
+}
+
+// memberRenames collects the "Owner.member: new" rules of m that belong to definition name in pkg.
+func memberRenames(m map[string]string, pkg, name string) map[string]string {
+	out := map[string]string{}
+	for k, v := range m {
+		dot := strings.LastIndex(k, ".")
+		if dot <= 0 {
+			continue
+		}
+		if owner := k[:dot]; owner == name || (pkg != "" && owner == pkg+"."+name) {
+			out[k[dot+1:]] = v
+		}
+	}
+	return out
+}
+
+// renameDefHeader replaces the name in the "message/enum/service Name" header of def.
+func renameDefHeader(def, name string) string {
+	loc := reDefHeader.FindStringSubmatchIndex(def)
+	if loc == nil {
+		return def
+	}
+	return def[:loc[4]] + name + def[loc[5]:]
+}
+
+// style returns the case style kind combined with the target's acronym dictionary.
+func (t exportTarget) style(kind string) caseStyle {
+	return caseStyle{Kind: kind, Acronyms: t.Acronyms}
+}
+
+// outTypePath maps rest, a type path relative to its package, to its output form: the top-level
+// definition top may be renamed, every other segment follows typeNameCase.
+func (t exportTarget) outTypePath(pkg, top, rest string) string {
+	segs := strings.Split(rest, ".")
+	for i, seg := range segs {
+		if i == 0 && seg == top {
+			if name, ok := t.renamedType(pkg, top); ok {
+				segs[i] = name
+				continue
+			}
+		}
+		segs[i] = t.style(t.TypeNameCase).apply(seg)
+	}
+	return strings.Join(segs, ".")
+}
+
+// transformTypeNames applies style to the message/enum/service names declared in def; nested
+// definitions of one scope that end up with the same name are reported as an error.
+func transformTypeNames(def string, style caseStyle) (string, error) {
+	if style.keep() {
+		return def, nil
+	}
+	lines := strings.Split(def, "\n")
+	// scopes 记录每层块中已声明的嵌套类型（输出名 -> 原名）；顶层定义由调用方按 package 检查
+	var scopes []map[string]string
+	for idx, line := range lines {
+		parts := splitStatements(line)
+		for k, ln := range parts {
+			if loc := reDefHeader.FindStringSubmatchIndex(ln); loc != nil {
+				orig := ln[loc[4]:loc[5]]
+				name := style.apply(orig)
+				if n := len(scopes); n > 0 {
+					if prev, ok := scopes[n-1][name]; ok && prev != orig {
+						return def, fmt.Errorf("%s 与 %s 都映射为 %s", prev, orig, name)
+					}
+					scopes[n-1][name] = orig
+				}
+				parts[k] = ln[:loc[4]] + name + ln[loc[5]:]
+			}
+			for _, c := range stripComments(ln) {
+				switch c {
+				case '{':
+					scopes = append(scopes, map[string]string{})
+				case '}':
+					if len(scopes) > 0 {
+						scopes = scopes[:len(scopes)-1]
+					}
+				}
+			}
+		}
+		lines[idx] = strings.Join(parts, "")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// transformEnumValues applies style (and prefix stripping) to the values of every enum in def,
+// including enums nested in a message; renames (old -> new) win for the values of a top-level enum.
+// Enum values share the scope of the enclosing block, so values that end up with the same name
+// there are reported as an error; top collects the values of top-level enums (output name ->
+// Enum.VALUE) across the definitions of one package and may be nil.
+func transformEnumValues(def string, style caseStyle, strip bool, renames map[string]string, top map[string]string) (string, error) {
+	if style.keep() && !strip && len(renames) == 0 {
+		return def, nil
+	}
+	lines := strings.Split(def, "\n")
+	// blocks 记录当前所处的块：enum 块的 enum 为枚举名；values 为该块内各枚举的值（输出名 -> Enum.VALUE）
+	type block struct {
+		enum   string
+		values map[string]string
+	}
+	blocks := []block{{values: top}}
+	for idx, line := range lines {
+		parts := splitStatements(line)
+		for k, ln := range parts {
+			if n := len(blocks); n > 1 && blocks[n-1].enum != "" {
+				if loc := reEnumValue.FindStringSubmatchIndex(ln); loc != nil {
+					enum, value := blocks[n-1].enum, ln[loc[4]:loc[5]]
+					name, ok := renames[value]
+					if !ok || n > 2 {
+						name = enumValueName(enum, value, style, strip)
+					}
+					if scope := blocks[n-2].values; scope != nil {
+						if prev, ok := scope[name]; ok && prev != enum+"."+value {
+							return def, fmt.Errorf("%s 与 %s 都映射为 %s", prev, enum+"."+value, name)
+						}
+						scope[name] = enum + "." + value
+					}
+					parts[k] = ln[:loc[4]] + name + ln[loc[5]:]
+				}
+			}
+			for _, c := range stripComments(ln) {
+				switch c {
+				case '{':
+					enum := ""
+					if m := reEnumHeader.FindStringSubmatch(ln); m != nil {
+						enum = m[1]
+					}
+					blocks = append(blocks, block{enum: enum, values: map[string]string{}})
+				case '}':
+					if len(blocks) > 1 {
+						blocks = blocks[:len(blocks)-1]
+					}
+				}
+			}
+		}
+		lines[idx] = strings.Join(parts, "")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// enumValueName applies style to a value of enum, optionally stripping the enum-name prefix
+// (COLOR_RED -> RED); all-caps values are lowercased first so that camel yields Red.
+func enumValueName(enum, value string, style caseStyle, strip bool) string {
+	v := value
+	if strip {
+		prefix := strings.ToUpper(toSnake(enum)) + "_"
+		if len(v) > len(prefix) && strings.EqualFold(v[:len(prefix)], prefix) && !isDigit(rune(v[len(prefix)])) {
+			v = v[len(prefix):]
+		}
+	}
+	if !style.keep() && v == strings.ToUpper(v) {
+		v = strings.ToLower(v)
+	}
+	return style.apply(v)
+}
+
+// jsonName returns protoc's default JSON name of a field: underscores are dropped and the
+// following letter is upper-cased (player_id -> playerId).
+func jsonName(field string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range field {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = toUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var reFieldNumber = regexp.MustCompile(`^[\t ]*=\s*\d+\s*`)
+
+// withJSONName adds json_name to the part of a field line after its name ("= 1 [opts]; ..."),
+// merging into existing options; an explicit json_name is left untouched.
+func withJSONName(rest, name string) string {
+	loc := reFieldNumber.FindStringIndex(rest)
+	if loc == nil {
+		return rest
+	}
+	opt := "json_name = " + strconv.Quote(name)
+	after := rest[loc[1]:]
+	if !strings.HasPrefix(after, "[") {
+		return strings.TrimRight(rest[:loc[1]], " \t") + " [" + opt + "]" + strings.TrimLeft(after, " \t")
+	}
+	end := closingBracket(after)
+	if end < 0 {
+		return rest
+	}
+	inner := after[1:end]
+	if regexp.MustCompile(`(^|[\s,])json_name\s*=`).MatchString(inner) {
+		return rest
+	}
+	if strings.TrimSpace(inner) != "" {
+		inner = strings.TrimRight(inner, " \t") + ", "
+	}
+	return rest[:loc[1]] + "[" + inner + opt + after[end:]
+}
+
+// closingBracket returns the index of the ']' matching s[0] == '[', skipping quoted strings.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// rewriteEnumDefaults replaces the enum value of every proto2 `[default = VALUE]` with fn(type, VALUE).
+func rewriteEnumDefaults(def string, fn func(typ, value string) string) string {
+	return reEnumDefault.ReplaceAllStringFunc(def, func(m string) string {
+		g := reEnumDefault.FindStringSubmatch(m)
+		switch g[3] {
+		case "true", "false", "inf", "nan":
+			return m
+		}
+		return g[1] + fn(g[2], g[3])
+	})
+}
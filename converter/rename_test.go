@@ -0,0 +1,182 @@
+package converter
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTransformFieldNamesRenames(t *testing.T) {
+	def := `message Player {
+  int64 player_id = 1;
+  optional string nick_name = 2;
+  oneof choice {
+    int32 player_level = 3;
+  }
+  message Inner {
+    int64 player_id = 1;
+    reserved 2 to 4;
+    reserved "player_level";
+  }
+  reserved "old_name", "player_id";
+}`
+	want := `message Player {
+  int64 id = 1;
+  optional string NickName = 2;
+  oneof choice {
+    int32 level = 3;
+  }
+  message Inner {
+    int64 PlayerId = 1;
+    reserved 2 to 4;
+    reserved "PlayerLevel";
+  }
+  reserved "OldName", "id";
+}`
+	got, err := transformFieldNames(def, caseStyle{Kind: "camel"}, caseStyle{}, map[string]string{"player_id": "id", "player_level": "level"}, false)
+	if err != nil || got != want {
+		t.Errorf("transformFieldNames =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenameEnumValues(t *testing.T) {
+	def := "enum ErrorCode {\n  option allow_alias = true;\n  ERROR_CODE_OK = 0;\n  ERROR_CODE_FAIL = -1;\n}"
+	want := "enum ErrorCode {\n  option allow_alias = true;\n  ERROR_CODE_OK = 0;\n  FAILED = -1;\n}"
+	if got, _ := transformEnumValues(def, caseStyle{Kind: "keep"}, false, map[string]string{"ERROR_CODE_FAIL": "FAILED"}, nil); got != want {
+		t.Errorf("transformEnumValues = %q, want %q", got, want)
+	}
+}
+
+func TestMemberRenames(t *testing.T) {
+	rules := map[string]string{"Player.id": "a", "game.Player.name": "b", "other.Player.x": "c", "Team.id": "d"}
+	got := memberRenames(rules, "game", "Player")
+	if len(got) != 2 || got["id"] != "a" || got["name"] != "b" {
+		t.Errorf("memberRenames = %v", got)
+	}
+}
+
+func TestEnumValueName(t *testing.T) {
+	tests := []struct {
+		enum, value, caseKind string
+		strip                 bool
+		out                   string
+	}{
+		{"Color", "COLOR_RED", "camel", true, "Red"},
+		{"Color", "COLOR_RED", "keep", true, "RED"},
+		{"Color", "COLOR_RED", "camel", false, "ColorRed"},
+		{"ErrorCode", "ERROR_CODE_NOT_FOUND", "snake", true, "not_found"},
+		{"Level", "LEVEL_1", "camel", true, "Level1"},
+		{"Color", "BLUE", "keep", true, "BLUE"},
+	}
+	for _, tt := range tests {
+		if got := enumValueName(tt.enum, tt.value, caseStyle{Kind: tt.caseKind}, tt.strip); got != tt.out {
+			t.Errorf("enumValueName(%q, %q, %q, %v) = %q, want %q", tt.enum, tt.value, tt.caseKind, tt.strip, got, tt.out)
+		}
+	}
+}
+
+func TestTransformTypeNames(t *testing.T) {
+	def := "message player_info {\n  enum kind_type { A = 0; }\n  message inner_one {}\n}"
+	want := "message PlayerInfo {\n  enum KindType { A = 0; }\n  message InnerOne {}\n}"
+	if got, _ := transformTypeNames(def, caseStyle{Kind: "camel"}); got != want {
+		t.Errorf("transformTypeNames = %q, want %q", got, want)
+	}
+}
+
+func TestWithJSONName(t *testing.T) {
+	tests := []struct {
+		rest, out string
+	}{
+		{" = 1;", ` = 1 [json_name = "playerId"];`},
+		{" = 1; // id", ` = 1 [json_name = "playerId"]; // id`},
+		{" = 1 [deprecated = true];", ` = 1 [deprecated = true, json_name = "playerId"];`},
+		{` = 1 [(v.rule) = "a]b"];`, ` = 1 [(v.rule) = "a]b", json_name = "playerId"];`},
+		{` = 1 [json_name = "pid"];`, ` = 1 [json_name = "pid"];`},
+	}
+	for _, tt := range tests {
+		if got := withJSONName(tt.rest, jsonName("player_id")); got != tt.out {
+			t.Errorf("withJSONName(%q) = %q, want %q", tt.rest, got, tt.out)
+		}
+	}
+}
+
+func TestNameCollisions(t *testing.T) {
+	if _, err := transformFieldNames("message M {\n  int32 foo_bar = 1;\n  oneof o {\n    int32 fooBar = 2;\n  }\n}", caseStyle{Kind: "camel"}, caseStyle{}, nil, false); err == nil {
+		t.Error("transformFieldNames: foo_bar/fooBar collision not reported")
+	}
+	if _, err := transformFieldNames("message M {\n  int32 foo_bar = 1;\n  message N {\n    int32 fooBar = 1;\n  }\n}", caseStyle{Kind: "camel"}, caseStyle{}, nil, false); err != nil {
+		t.Errorf("transformFieldNames: fields of different messages reported: %v", err)
+	}
+	camel := caseStyle{Kind: "camel"}
+	for _, tt := range []struct {
+		def         string
+		field, kind caseStyle
+		want        string
+	}{
+		{"message M {\n  Inner inner = 4;\n  message Inner {}\n}", camel, caseStyle{}, "字段 inner 与 message Inner 都映射为 Inner"},
+		{"message M {\n  message item_info {}\n  ItemInfo item_info = 1;\n}", camel, camel, "message item_info 与 字段 item_info 都映射为 ItemInfo"},
+		{"message M {\n  oneof choice {\n    int32 a = 1;\n  }\n  int32 Choice = 2;\n}", caseStyle{Kind: "lowerCamel"}, caseStyle{}, "oneof choice 与 字段 Choice 都映射为 choice"},
+		{"message M {\n  enum kind { K = 0; }\n  int32 Kind = 1;\n}", caseStyle{}, camel, "enum kind 与 字段 Kind 都映射为 Kind"},
+	} {
+		_, err := transformFieldNames(tt.def, tt.field, tt.kind, nil, false)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("transformFieldNames(%q) = %v, want %q", tt.def, err, tt.want)
+		}
+	}
+	if _, err := transformTypeNames("message M {\n  message foo_bar {}\n  enum FooBar { A = 0; }\n}", caseStyle{Kind: "camel"}); err == nil {
+		t.Error("transformTypeNames: foo_bar/FooBar collision not reported")
+	}
+	top := map[string]string{}
+	if _, err := transformEnumValues("enum Color {\n  COLOR_RED = 0;\n}", caseStyle{Kind: "camel"}, true, nil, top); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transformEnumValues("enum Light {\n  LIGHT_RED = 0;\n}", caseStyle{Kind: "camel"}, true, nil, top); err == nil {
+		t.Error("transformEnumValues: Color.COLOR_RED/Light.LIGHT_RED collision not reported")
+	}
+}
+
+func TestSingleLineStatements(t *testing.T) {
+	def := "enum Kind { KIND_A = 0; KIND_B = 1; } // KIND_C = 2;"
+	want := "enum Kind { A = 0; B = 1; } // KIND_C = 2;"
+	if got, err := transformEnumValues(def, caseStyle{Kind: "SCREAMING_SNAKE"}, true, nil, nil); err != nil || got != want {
+		t.Errorf("transformEnumValues = %q, %v, want %q", got, err, want)
+	}
+	def = "message M { int32 a_b = 1; int32 c_d = 2 [(x) = { n: 1; }]; string e_f = 3; }"
+	want = `message M { int32 aB = 1 [json_name = "aB"]; int32 cD = 2 [(x) = { n: 1; }, json_name = "cD"]; string eF = 3 [json_name = "eF"]; }`
+	if got, err := transformFieldNames(def, caseStyle{Kind: "lowerCamel"}, caseStyle{}, map[string]string{"a_b": "aB"}, true); err != nil || got != want {
+		t.Errorf("transformFieldNames =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := transformFieldNames("message M { int32 a_b = 1; int32 aB = 2; }", caseStyle{Kind: "lowerCamel"}, caseStyle{}, nil, false); err == nil {
+		t.Error("transformFieldNames: a_b/aB collision on one line not reported")
+	}
+}
+
+func TestExportSingleLineEnumDefault(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/kind.proto": "syntax = \"proto2\";\npackage game;\nenum Kind { KIND_A = 0; KIND_B = 1; }\nmessage Item { optional Kind kind = 1 [default = KIND_B]; }\n",
+		"cfg.yaml": `import:
+  dir: proto
+export:
+  dir: out
+  language: csharp
+  enumValueCase: camel
+  stripEnumPrefix: true
+  descriptorSet: out/set.pb
+  keep:
+    files:
+      - file: kind
+`,
+	})
+	if err := (&Exporter{ConfigPath: "cfg.yaml"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile("out/kind.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"A = 0; B = 1;", "[default = B]"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("out/kind.proto lacks %q:\n%s", want, b)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	reRefField  = regexp.MustCompile(`(?m)(?:^|[\s{;])(?:(?:repeated|optional|required)\s+)?(\.?[A-Za-z_][\w\.]*)\s+[A-Za-z_]\w*\s*=\s*\d+`)
+	reRefMap    = regexp.MustCompile(`map\s*<\s*(\.?[A-Za-z_][\w\.]*)\s*,\s*(\.?[A-Za-z_][\w\.]*)\s*>`)
+	reRefRPC    = regexp.MustCompile(`rpc\s+\w+\s*\(\s*(?:stream\s+)?(\.?[A-Za-z_][\w\.]*)\s*\)\s*returns\s*\(\s*(?:stream\s+)?(\.?[A-Za-z_][\w\.]*)\s*\)`)
+	reRefExtend = regexp.MustCompile(`extend\s+(\.?[A-Za-z_][\w\.]*)\s*\{`)
+	reRefOption = regexp.MustCompile(`(?:\boption\s+|[\[,]\s*)\(\s*(\.?[A-Za-z_][\w\.]*)\s*\)`)
+)
+
+// rewriteTypeRefs replaces every type reference in a definition — field types, map key/value,
+// rpc request/response, extend targets and custom option names — with fn(token, option),
+// where option marks custom option names.
+func rewriteTypeRefs(def string, fn func(tok string, option bool) string) string {
+	type span struct {
+		start, end int
+		option     bool
+	}
+	var spans []span
+	seen := map[int]bool{}
+	for _, re := range []*regexp.Regexp{reRefField, reRefMap, reRefRPC, reRefExtend, reRefOption} {
+		for _, m := range re.FindAllStringSubmatchIndex(def, -1) {
+			for g := 2; g+1 < len(m); g += 2 {
+				if m[g] < 0 || seen[m[g]] {
+					continue
+				}
+				seen[m[g]] = true
+				spans = append(spans, span{m[g], m[g+1], re == reRefOption})
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for _, sp := range spans {
+		tok := def[sp.start:sp.end]
+		if rep := fn(tok, sp.option); rep != tok {
+			def = def[:sp.start] + rep + def[sp.end:]
+		}
+	}
+	return def
+}
+
+// rewritePackage maps a proto package through flattenPackage or the longest matching
+// packageRewrite prefix (game -> client turns game.shared into client.shared).
+func (t exportTarget) rewritePackage(pkg string) string {
+	if t.FlattenPackage != "" {
+		return t.FlattenPackage
+	}
+	best := ""
+	for from := range t.PackageRewrite {
+		if (pkg == from || strings.HasPrefix(pkg, from+".")) && len(from) > len(best) {
+			best = from
+		}
+	}
+	if best == "" {
+		return pkg
+	}
+	return strings.Trim(t.PackageRewrite[best]+pkg[len(best):], ".")
+}
+
+func (t exportTarget) rewritesPackages() bool {
+	return t.FlattenPackage != "" || len(t.PackageRewrite) > 0
+}
+
+// qualify renders rest (a name relative to package pkg) as seen from package self.
+func qualify(pkg, self, rest string) string {
+	if pkg == "" || pkg == self {
+		return rest
+	}
+	return pkg + "." + rest
+}
+
+// splitKnownPackage splits tok into the longest package of pkgs it starts with and the remainder.
+func splitKnownPackage(tok string, pkgs map[string]struct{}) (string, string, bool) {
+	t := strings.TrimPrefix(tok, ".")
+	best := ""
+	for p := range pkgs {
+		if strings.HasPrefix(t, p+".") && len(p) > len(best) {
+			best = p
+		}
+	}
+	if best == "" {
+		return "", t, false
+	}
+	return best, t[len(best)+1:], true
+}
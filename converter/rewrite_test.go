@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRewritePackage(t *testing.T) {
+	rw := exportTarget{PackageRewrite: map[string]string{"game": "client", "game.shared": "common", "x": ""}}
+	tests := []struct {
+		t    exportTarget
+		pkg  string
+		want string
+	}{
+		{rw, "game", "client"},
+		{rw, "game.cli", "client.cli"},
+		{rw, "game.shared.v1", "common.v1"},
+		{rw, "gamex", "gamex"},
+		{rw, "x.y", "y"},
+		{rw, "", ""},
+		{exportTarget{FlattenPackage: "flat", PackageRewrite: map[string]string{"game": "client"}}, "game.cli", "flat"},
+	}
+	for _, tt := range tests {
+		if got := tt.t.rewritePackage(tt.pkg); got != tt.want {
+			t.Errorf("rewritePackage(%q) = %q, want %q", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestExportRewritesPackages(t *testing.T) {
+	writeWorkspace(t, map[string]string{
+		"proto/game/shared.proto": `syntax = "proto3";
+package game.shared;
+message Item { int32 id = 1; }
+enum Kind { KIND_NONE = 0; }
+`,
+		"proto/game/cli.proto": `syntax = "proto3";
+package game.cli;
+import "game/shared.proto";
+message Bag {
+  repeated game.shared.Item items = 1;
+  map<string, .game.shared.Item> by_name = 2;
+  game.shared.Kind kind = 3;
+}
+service Shop { rpc Buy (game.shared.Item) returns (Bag); }
+`,
+	})
+	keep := "import:\n  dir: proto\n  keep:\n    files:\n      - file: game/cli\n"
+	tests := []struct {
+		name   string
+		export string
+		want   map[string][]string
+	}{
+		{"packageRewrite", "export:\n  language: csharp\n  dir: out\n  deriveNamespace: true\n  packageRewrite:\n    game: client\n", map[string][]string{
+			"out/cli.proto": {
+				"package client.cli;",
+				`option csharp_namespace = "Client.Cli";`,
+				"repeated client.shared.Item items = 1;",
+				"map<string, client.shared.Item> by_name = 2;",
+				"client.shared.Kind kind = 3;",
+				"rpc Buy (client.shared.Item) returns (Bag);",
+			},
+			"out/shared.proto": {"package client.shared;", `option csharp_namespace = "Client.Shared";`},
+		}},
+		{"flattenPackage", "export:\n  language: go\n  dir: out\n  flattenPackage: pb\n  languageOptions:\n    goModule: example.com/m\n", map[string][]string{
+			"out/cli.proto": {
+				"package pb;",
+				`option go_package = "example.com/m/pb;pb";`,
+				"repeated Item items = 1;",
+				"map<string, Item> by_name = 2;",
+				"Kind kind = 3;",
+				"rpc Buy (Item) returns (Bag);",
+			},
+			"out/shared.proto": {"package pb;", `option go_package = "example.com/m/pb;pb";`},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile("cfg.yaml", []byte(keep+tt.export), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.RemoveAll("out"); err != nil {
+				t.Fatal(err)
+			}
+			if err := (&Exporter{ConfigPath: "cfg.yaml"}).Run(); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range sortedKeys(tt.want) {
+				data, err := os.ReadFile(filepath.FromSlash(name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, want := range tt.want[name] {
+					if !strings.Contains(string(data), want) {
+						t.Errorf("%s lacks %q:\n%s", name, want, data)
+					}
+				}
+			}
+		})
+	}
+}
@@ -25,8 +25,11 @@ func ensureDir(dir string, dry bool) error {
 func normalizeItem(s string) (protoItem, error) {
 	s = strings.TrimSpace(s)
 	s = strings.TrimPrefix(s, "./")
-	s = strings.TrimPrefix(s, "/")
-	s = strings.TrimPrefix(s, "\\")
+	// 已存在的绝对路径（如临时 import 目录下的文件）保持原样
+	if !filepath.IsAbs(s) || !exists(s) {
+		s = strings.TrimPrefix(s, "/")
+		s = strings.TrimPrefix(s, "\\")
+	}
 	if s == "" {
 		return protoItem{}, fmt.Errorf("空的 proto 条目")
 	}
@@ -42,4 +45,19 @@ func shortPath(p string) string { return filepath.ToSlash(p) }
 
 func trimExt(name string) string { return strings.TrimSuffix(name, filepath.Ext(name)) }
 
-var importRe = regexp.MustCompile(`(?m)^\s*import\s+\"([^\"]+)\"\s*;`)
+var importRe = regexp.MustCompile(`(?m)^\s*import\s+(?:(public|weak)\s+)?\"([^\"]+)\"\s*;`)
+
+// protoImport is one import statement; Kind is "public", "weak" or empty.
+type protoImport struct {
+	Path string
+	Kind string
+}
+
+// parseImports returns the import statements of comment-free src in order.
+func parseImports(src string) []protoImport {
+	var out []protoImport
+	for _, m := range importRe.FindAllStringSubmatch(src, -1) {
+		out = append(out, protoImport{Path: strings.TrimSpace(m[2]), Kind: m[1]})
+	}
+	return out
+}
@@ -2,4 +2,7 @@ module github.com/aura-studio/proto-converter
 
 go 1.21
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	google.golang.org/protobuf v1.36.5
+	gopkg.in/yaml.v3 v3.0.1
+)
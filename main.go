@@ -5,16 +5,70 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/aura-studio/proto-converter/converter"
 )
 
+// envPrefix 是覆盖配置的环境变量前缀，例如 PROTO_CONVERTER_LANGUAGE=go。
+const envPrefix = "PROTO_CONVERTER_"
+
+type setFlags []string
+
+func (s *setFlags) String() string     { return strings.Join(*s, ",") }
+func (s *setFlags) Set(v string) error { *s = append(*s, v); return nil }
+
 func main() {
 	configPath := flag.String("c", filepath.FromSlash("template.proto.yaml"), "YAML 配置文件路径（相对运行目录）")
 	flag.StringVar(configPath, "config", *configPath, "YAML 配置文件路径（同 -c）")
-	workdir := flag.String("w", ".", "工作目录（相对运行目录），YAML 中的路径以此为基准")
+	workdir := flag.String("w", ".", "工作目录（相对运行目录），命令行参数中的路径以此为基准")
 	flag.StringVar(workdir, "workdir", *workdir, "工作目录（同 -w）")
-	flag.Parse()
+	strict := flag.Bool("strict", false, "keep 规则未匹配到任何定义/字段时报错（默认仅警告）")
+	schemaOut := flag.String("schema", "", "将配置文件的 JSON Schema 写到指定路径后退出（- 表示标准输出）")
+
+	// 以下参数覆盖配置文件（优先级：命令行 > 环境变量 > 配置 > 默认值），作用于每个导出目标
+	exportDir := flag.String("export-dir", "", "输出目录，覆盖 export.dir（环境变量 "+envPrefix+"EXPORT_DIR）")
+	importDir := flag.String("import-dir", "", "源码根目录，覆盖 import.dir（环境变量 "+envPrefix+"IMPORT_DIR）")
+	namespace := flag.String("namespace", "", "命名空间，覆盖 export.namespace（环境变量 "+envPrefix+"NAMESPACE）")
+	language := flag.String("language", "", "目标语言，覆盖 export.language（环境变量 "+envPrefix+"LANGUAGE）")
+	fileNameCase := flag.String("file-name-case", "", "文件名风格，覆盖 export.fileNameCase（环境变量 "+envPrefix+"FILE_NAME_CASE）")
+	fieldNameCase := flag.String("field-name-case", "", "字段名风格，覆盖 export.fieldNameCase（环境变量 "+envPrefix+"FIELD_NAME_CASE）")
+	prune := flag.Bool("prune", true, "是否裁剪，覆盖 import.prune（环境变量 "+envPrefix+"PRUNE）")
+	dryRun := flag.Bool("dry-run", false, "演练模式，覆盖 dryRun（环境变量 "+envPrefix+"DRY_RUN）")
+	var sets setFlags
+	flag.Var(&sets, "set", "覆盖任意配置项，形如 export.namespace=Game.Proto 或 exports[0].dir=out（可重复）")
+	against := flag.String("against", "", "breaking 子命令：上次导出的目录、清单（export.descriptorSet/descriptorJson 写出的 .pb/.json 描述符集）或 git:<ref>")
+
+	// 子命令（可选）：breaking 将本次导出与上次导出比较，lint 检查源文件与导出结果；两者都不写出文件
+	cmd, err := parseCommand(flag.CommandLine, os.Args[1:])
+	switch {
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	case cmd == "breaking" && *against == "":
+		fmt.Fprintln(os.Stderr, "breaking 需要 --against <目录|清单|git:ref>")
+		os.Exit(2)
+	case cmd != "breaking" && *against != "":
+		fmt.Fprintln(os.Stderr, "--against 仅用于 breaking 子命令")
+		os.Exit(2)
+	}
+
+	if *schemaOut != "" {
+		data, err := converter.ConfigJSONSchema()
+		if err == nil {
+			data = append(data, '\n')
+			if *schemaOut == "-" {
+				_, err = os.Stdout.Write(data)
+			} else {
+				err = os.WriteFile(*schemaOut, data, 0o644)
+			}
+		}
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+		}
+		return
+	}
 
 	startWD, _ := os.Getwd()
 	configAbs := *configPath
@@ -30,11 +84,138 @@ func main() {
 		return
 	}
 
-	exp := &converter.Exporter{}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	env, err := envOverrides(os.Getenv, given)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return
+	}
+
+	// 命令行参数直接作用于每个导出目标；环境变量经 Env 写入配置，优先级低于 --set
+	exp := &converter.Exporter{ConfigPath: configAbs, Set: sets, Env: env, Strict: *strict}
+	if given["export-dir"] {
+		exp.ExportDir = filepath.FromSlash(*exportDir)
+	}
+	if given["import-dir"] {
+		exp.ImportDir = filepath.FromSlash(*importDir)
+	}
+	exp.Namespace = *namespace
+	exp.Language = *language
+	exp.FileNameCase = *fileNameCase
+	exp.FieldNameCase = *fieldNameCase
+	if given["prune"] {
+		exp.SetPrune(*prune)
+	}
+	if given["dry-run"] {
+		exp.SetDryRun(*dryRun)
+	}
+	if s := os.Getenv(envName("strict")); s != "" && !given["strict"] {
+		if exp.Strict, err = strconv.ParseBool(s); err != nil {
+			fmt.Printf("错误: 环境变量 %s 不是布尔值: %q\n", envName("strict"), s)
+			return
+		}
+	}
 
-	exp.ConfigPath = configAbs
+	switch cmd {
+	case "breaking":
+		os.Exit(runBreaking(exp, *against))
+	case "lint":
+		failed, err := exp.Lint(os.Stdout)
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			os.Exit(2)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
 	if err := exp.Run(); err != nil {
 		fmt.Printf("错误: %v\n", err)
 		return
 	}
 }
+
+// runBreaking prints the changes against the previous export and returns the exit code:
+// 1 when a change is wire-incompatible, 2 when the comparison itself failed.
+func runBreaking(exp *converter.Exporter, against string) int {
+	changes, err := exp.Breaking(against)
+	if err != nil {
+		fmt.Printf("错误: %v\n", err)
+		return 2
+	}
+	count := map[converter.Severity]int{}
+	for _, c := range changes {
+		fmt.Println(c)
+		count[c.Severity]++
+	}
+	fmt.Printf("共 %d 处变更（error %d，warning %d，info %d）\n", len(changes), count[converter.SeverityError], count[converter.SeverityWarning], count[converter.SeverityInfo])
+	if converter.HasErrors(changes) {
+		return 1
+	}
+	return 0
+}
+
+// parseCommand parses args into fs and returns the subcommand, which may appear before, between
+// or after the flags. Anything else left over is an error.
+func parseCommand(fs *flag.FlagSet, args []string) (string, error) {
+	cmd := ""
+	for {
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() == 0 {
+			return cmd, nil
+		}
+		if cmd != "" {
+			return "", fmt.Errorf("多余的参数: %s", strings.Join(fs.Args(), " "))
+		}
+		cmd, args = fs.Arg(0), fs.Args()[1:]
+		if cmd != "breaking" && cmd != "lint" {
+			return "", fmt.Errorf("未知的子命令: %s（可用: breaking、lint）", cmd)
+		}
+	}
+}
+
+// envPaths maps the flags that environment variables can stand in for to their config paths.
+var envPaths = []struct{ flag, path string }{
+	{"export-dir", "export.dir"},
+	{"import-dir", "import.dir"},
+	{"namespace", "export.namespace"},
+	{"language", "export.language"},
+	{"file-name-case", "export.fileNameCase"},
+	{"field-name-case", "export.fieldNameCase"},
+	{"prune", "import.prune"},
+	{"dry-run", "dryRun"},
+}
+
+// envOverrides returns the "path=value" config overrides for the environment variables that
+// are set, skipping flags given on the command line.
+func envOverrides(getenv func(string) string, given map[string]bool) ([]string, error) {
+	var out []string
+	for _, e := range envPaths {
+		v := getenv(envName(e.flag))
+		if v == "" || given[e.flag] {
+			continue
+		}
+		switch e.flag {
+		case "prune", "dry-run":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("环境变量 %s 不是布尔值: %q", envName(e.flag), v)
+			}
+			v = strconv.FormatBool(b)
+		default:
+			// 按字符串写入，避免 YAML 把 on、1.0 等解析成其它类型
+			v = strconv.Quote(v)
+		}
+		out = append(out, e.path+"="+v)
+	}
+	return out, nil
+}
+
+// envName maps a flag name to its environment variable, e.g. export-dir -> PROTO_CONVERTER_EXPORT_DIR.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aura-studio/proto-converter/converter"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		cmd     string
+		against string
+		wantErr bool
+	}{
+		{args: []string{"-c", "cfg.yaml"}},
+		{args: []string{"breaking", "--against", "prev"}, cmd: "breaking", against: "prev"},
+		{args: []string{"-c", "cfg.yaml", "breaking", "--against", "prev"}, cmd: "breaking", against: "prev"},
+		{args: []string{"-c", "cfg.yaml", "lint"}, cmd: "lint"},
+		{args: []string{"lint", "extra"}, wantErr: true},
+		{args: []string{"-c", "cfg.yaml", "export"}, wantErr: true},
+		{args: []string{"--bogus"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.String("c", "", "")
+		against := fs.String("against", "", "")
+		cmd, err := parseCommand(fs, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCommand(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if cmd != tt.cmd || *against != tt.against {
+			t.Errorf("parseCommand(%q) = %q, against %q; want %q, %q", tt.args, cmd, *against, tt.cmd, tt.against)
+		}
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	env := map[string]string{
+		envPrefix + "NAMESPACE":  "Game.Proto",
+		envPrefix + "LANGUAGE":   "csharp",
+		envPrefix + "EXPORT_DIR": "out/cs",
+		envPrefix + "PRUNE":      "0",
+	}
+	got, err := envOverrides(func(k string) string { return env[k] }, map[string]bool{"language": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`export.dir="out/cs"`, `export.namespace="Game.Proto"`, "import.prune=false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envOverrides = %q, want %q", got, want)
+	}
+	env[envPrefix+"DRY_RUN"] = "maybe"
+	if _, err := envOverrides(func(k string) string { return env[k] }, nil); err == nil {
+		t.Error("envOverrides accepted a non-boolean PROTO_CONVERTER_DRY_RUN")
+	}
+}
+
+// TestOverridePrecedence runs exports to check flag > --set > env > config.
+func TestOverridePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cfg.yaml":      "import:\n  dir: proto\n  keep:\n    files:\n      - file: a\nexport:\n  language: csharp\n  namespace: Config.Ns\n  dir: out\n",
+		"proto/a.proto": "syntax = \"proto3\";\npackage demo;\nmessage A { int32 id = 1; }\n",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	tests := []struct {
+		name, env, flag string
+		sets            []string
+		want            string
+	}{
+		{name: "config", want: "Config.Ns"},
+		{name: "env", env: "Env.Ns", want: "Env.Ns"},
+		{name: "set beats env", env: "Env.Ns", sets: []string{"export.namespace=Set.Ns"}, want: "Set.Ns"},
+		{name: "flag beats set", env: "Env.Ns", flag: "Flag.Ns", sets: []string{"export.namespace=Set.Ns"}, want: "Flag.Ns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envName("namespace"), tt.env)
+			given := map[string]bool{"namespace": tt.flag != ""}
+			env, err := envOverrides(os.Getenv, given)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := &converter.Exporter{ConfigPath: "cfg.yaml", Namespace: tt.flag, Set: tt.sets, Env: env}
+			if err := exp.Run(); err != nil {
+				t.Fatal(err)
+			}
+			out, err := os.ReadFile(filepath.Join("out", "a.proto"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `option csharp_namespace = "` + tt.want + `";`; !strings.Contains(string(out), want) {
+				t.Errorf("output lacks %s:\n%s", want, out)
+			}
+		})
+	}
+}
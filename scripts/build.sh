@@ -8,4 +8,7 @@ go mod tidy
 echo "== Build proto-converter.exe =="
 go build -o proto-converter.exe ./
 
+echo "== Generate config JSON Schema =="
+./proto-converter.exe -schema proto-converter.schema.json
+
 echo "Build complete: proto-converter.exe"